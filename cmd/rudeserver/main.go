@@ -1,18 +1,35 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
 	"time"
 
+	"rudeserver/internal/admin"
+	"rudeserver/internal/capacity"
+	"rudeserver/internal/chaos"
+	"rudeserver/internal/config"
 	"rudeserver/internal/httpserver"
 	"rudeserver/internal/openapi"
 	"rudeserver/internal/ratelimit"
 	"rudeserver/internal/reqlog"
+	"rudeserver/internal/schedule"
+	"rudeserver/internal/sequence"
+	"rudeserver/internal/stub"
 	"rudeserver/internal/ui"
 )
 
 func main() {
+	configPath := flag.String("config", "", "route configuration file (YAML or JSON), reloaded when it changes")
+	configInterval := flag.Duration("config-interval", 2*time.Second, "how often to check the configuration file for changes")
+	rlMaxKeys := flag.Int("rl-max-keys", ratelimit.DefaultMaxEntries, "rate limiters to keep before evicting the least recently used")
+	rlIdleTTL := flag.Duration("rl-idle-ttl", ratelimit.DefaultIdleTTL, "drop rate limiters unused for this long")
+	stateIdleTTL := flag.Duration("state-idle-ttl", 10*time.Minute, "forget sequence cursors, seeded streams and schedule clocks unused for this long")
+	logSize := flag.Int("log-size", 100, "requests to keep in the request log, which /admin/verify checks against")
+	flag.Parse()
+
 	mux := http.NewServeMux()
 
 	if err := openapi.Register(mux); err != nil {
@@ -23,16 +40,53 @@ func main() {
 	if err != nil {
 		log.Fatalf("ui setup error: %v", err)
 	}
-	mux.Handle("/", uiHandler)
+	mux.Handle("/{$}", uiHandler)
+	mux.Handle("/ui/", uiHandler)
+
+	limits := ratelimit.NewStoreWithLimits(ratelimit.Limits{MaxEntries: *rlMaxKeys, IdleTTL: *rlIdleTTL})
+	go limits.Run(context.Background(), time.Minute)
+
+	sequences := sequence.NewStore()
+	streams := chaos.NewStore()
+	schedules := schedule.NewStore()
+	go sweep(context.Background(), time.Minute, *stateIdleTTL, sequences, streams, schedules)
+
+	stubs := stub.NewStore()
+	opts := httpserver.Options{
+		RateLimit: limits,
+		Sequence:  sequences,
+		Chaos:     streams,
+		Schedule:  schedules,
+		Capacity:  capacity.NewStore(),
+		// Stubs registered through the admin API win over the config file.
+		Resolvers: []httpserver.Resolver{stubs},
+	}
+	if *configPath != "" {
+		watcher, err := config.NewWatcher(*configPath)
+		if err != nil {
+			log.Fatalf("config error: %v", err)
+		}
+		go watcher.Run(context.Background(), *configInterval)
+		opts.Resolvers = append(opts.Resolvers, watcher)
+		cfg := watcher.Config()
+		log.Printf("serving %d configured routes and %d stubs from %s", len(cfg.Routes), len(cfg.Stubs), *configPath)
+	}
 
-	logStore := reqlog.NewStore(100)
-	apiHandler := httpserver.NewRouter(ratelimit.NewStore())
+	logStore := reqlog.NewStore(*logSize)
+	apiHandler := httpserver.New(opts)
 	loggedAPI := reqlog.Middleware(logStore, apiHandler)
 
 	mux.Handle("/ui/api/", ui.APIHandler(logStore))
-	mux.Handle("/http/", loggedAPI)
-	mux.Handle("/rest/", loggedAPI)
-	mux.Handle("/jsonrpc/", loggedAPI)
+	mux.Handle("/admin/", admin.Handler(admin.Options{
+		Stubs:     stubs,
+		Log:       logStore,
+		RateLimit: opts.RateLimit,
+		Sequence:  opts.Sequence,
+		Chaos:     opts.Chaos,
+		Schedule:  opts.Schedule,
+	}))
+	// Smart URLs (/http, /rest, /jsonrpc) and configured routes share the catch-all.
+	mux.Handle("/", loggedAPI)
 
 	srv := &http.Server{
 		Addr:              ":8080",
@@ -45,3 +99,24 @@ func main() {
 		log.Fatalf("server error: %v", err)
 	}
 }
+
+type sweeper interface {
+	Sweep(now time.Time, ttl time.Duration)
+}
+
+// sweep drops state idle for longer than ttl from every store, each
+// interval until ctx is done.
+func sweep(ctx context.Context, interval time.Duration, ttl time.Duration, stores ...sweeper) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, s := range stores {
+				s.Sweep(now, ttl)
+			}
+		}
+	}
+}
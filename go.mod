@@ -3,6 +3,6 @@ module rudeserver
 go 1.25.4
 
 require (
-	golang.org/x/time v0.14.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/time v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
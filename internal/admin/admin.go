@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"rudeserver/internal/chaos"
+	"rudeserver/internal/ratelimit"
+	"rudeserver/internal/reqlog"
+	"rudeserver/internal/schedule"
+	"rudeserver/internal/sequence"
+	"rudeserver/internal/stub"
+)
+
+// maxBodySize caps the size of request bodies accepted by the API.
+const maxBodySize = 1 << 20
+
+// Options holds the stores the API manages. The limiter endpoints need
+// RateLimit; DELETE /admin/state resets whichever stores are set.
+type Options struct {
+	Stubs     *stub.Store
+	Log       *reqlog.Store
+	RateLimit *ratelimit.Store
+	Sequence  *sequence.Store
+	Chaos     *chaos.Store
+	Schedule  *schedule.Store
+}
+
+// Handler serves the runtime admin API under /admin.
+func Handler(opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/admin")
+		switch {
+		case path == "/stubs":
+			handleStubs(w, r, opts.Stubs)
+		case strings.HasPrefix(path, "/stubs/"):
+			handleStub(w, r, opts.Stubs, strings.TrimPrefix(path, "/stubs/"))
+		case path == "/verify":
+			handleVerify(w, r, opts.Log)
+		case path == "/limiters" && opts.RateLimit != nil:
+			handleLimiters(w, r, opts.RateLimit)
+		case strings.HasPrefix(path, "/limiters/") && opts.RateLimit != nil:
+			handleLimiter(w, r, opts.RateLimit, strings.TrimPrefix(path, "/limiters/"))
+		case path == "/state":
+			handleState(w, r, opts)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func handleStubs(w http.ResponseWriter, r *http.Request, stubs *stub.Store) {
+	switch r.Method {
+	case http.MethodGet:
+		items := stubs.List()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"total": len(items),
+			"items": items,
+		})
+	case http.MethodPost:
+		var s stub.Stub
+		if !decode(w, r, &s) {
+			return
+		}
+		added, err := stubs.Add(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, added)
+	case http.MethodDelete:
+		_ = stubs.Replace(nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, POST, DELETE")
+	}
+}
+
+func handleStub(w http.ResponseWriter, r *http.Request, stubs *stub.Store, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		s, ok := stubs.Get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "stub not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+	case http.MethodPut:
+		var s stub.Stub
+		if !decode(w, r, &s) {
+			return
+		}
+		updated, ok, err := stubs.Update(id, s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "stub not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		if !stubs.Remove(id) {
+			writeError(w, http.StatusNotFound, "stub not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, PUT, DELETE")
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rudeserver/internal/stub"
+)
+
+func do(t *testing.T, h http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStubsLifecycle(t *testing.T) {
+	stubs := stub.NewStore()
+	h := Handler(Options{Stubs: stubs})
+
+	rec := do(t, h, http.MethodPost, "/admin/stubs", `{"name":"slow","request":{"method":"GET","path":"/v1/slow"},"response":{"status":504,"delay":"1s"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var created stub.Stub
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.ID == "" {
+		t.Fatalf("created = %+v, err = %v", created, err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/slow", nil)
+	if sc, ok, _ := stubs.Resolve(r); !ok || sc.StatusCode != 504 {
+		t.Fatalf("resolve = %+v, %v", sc, ok)
+	}
+
+	rec = do(t, h, http.MethodGet, "/admin/stubs", "")
+	var list struct {
+		Total int         `json:"total"`
+		Items []stub.Stub `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || list.Total != 1 || list.Items[0].Name != "slow" {
+		t.Fatalf("list = %s", rec.Body.String())
+	}
+
+	rec = do(t, h, http.MethodPut, "/admin/stubs/"+created.ID, `{"name":"fast","request":{"path":"/v1/slow"},"response":{"status":200}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	rec = do(t, h, http.MethodGet, "/admin/stubs/"+created.ID, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"fast"`) {
+		t.Fatalf("get = %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = do(t, h, http.MethodDelete, "/admin/stubs/"+created.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", rec.Code)
+	}
+	if rec = do(t, h, http.MethodDelete, "/admin/stubs/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("second delete status = %d", rec.Code)
+	}
+	if _, ok, _ := stubs.Resolve(httptest.NewRequest(http.MethodGet, "/v1/slow", nil)); ok {
+		t.Fatal("deleted stub still resolves")
+	}
+}
+
+func TestStubsRejectsInvalidInput(t *testing.T) {
+	h := Handler(Options{Stubs: stub.NewStore()})
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, "/admin/stubs", `{`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/stubs", `{"request":{"paht":"/x"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/stubs", `{"request":{"path_pattern":"("}}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/stubs", `{"response":{"status":999}}`, http.StatusBadRequest},
+		{http.MethodPut, "/admin/stubs/missing", `{}`, http.StatusNotFound},
+		{http.MethodPatch, "/admin/stubs", ``, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/nothing", ``, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if rec := do(t, h, tc.method, tc.path, tc.body); rec.Code != tc.want {
+			t.Fatalf("%s %s %s: status = %d, want %d", tc.method, tc.path, tc.body, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestStubsDeleteAll(t *testing.T) {
+	stubs := stub.NewStore()
+	h := Handler(Options{Stubs: stubs})
+	for range 3 {
+		do(t, h, http.MethodPost, "/admin/stubs", `{"response":{"status":200}}`)
+	}
+	if rec := do(t, h, http.MethodDelete, "/admin/stubs", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if n := len(stubs.List()); n != 0 {
+		t.Fatalf("stubs left = %d", n)
+	}
+}
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"rudeserver/internal/ratelimit"
+)
+
+func handleLimiters(w http.ResponseWriter, r *http.Request, limits *ratelimit.Store) {
+	prefix := r.URL.Query().Get("prefix")
+	switch r.Method {
+	case http.MethodGet:
+		items := limits.Keys(prefix, time.Now())
+		writeJSON(w, http.StatusOK, map[string]any{
+			"total": len(items),
+			"items": items,
+			"stats": limits.Stats(),
+		})
+	case http.MethodDelete:
+		writeJSON(w, http.StatusOK, map[string]int{"removed": limits.ResetPrefix(prefix)})
+	default:
+		methodNotAllowed(w, "GET, DELETE")
+	}
+}
+
+// handleLimiter serves one key. Keys contain "|", which clients escape
+// as %7C.
+func handleLimiter(w http.ResponseWriter, r *http.Request, limits *ratelimit.Store, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		info, ok := limits.Lookup(key, time.Now())
+		if !ok {
+			writeError(w, http.StatusNotFound, "limiter not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	case http.MethodDelete:
+		if !limits.Reset(key) {
+			writeError(w, http.StatusNotFound, "limiter not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, DELETE")
+	}
+}
+
+// handleState resets all per-key state between test cases: limiters,
+// sequence cursors and call counts, seeded chaos streams and schedule
+// clocks. Capacity pools only hold requests in flight and are left alone.
+func handleState(w http.ResponseWriter, r *http.Request, opts Options) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, "DELETE")
+		return
+	}
+	if opts.RateLimit != nil {
+		opts.RateLimit.ResetPrefix("")
+	}
+	if opts.Sequence != nil {
+		opts.Sequence.Reset()
+	}
+	if opts.Chaos != nil {
+		opts.Chaos.Reset()
+	}
+	if opts.Schedule != nil {
+		opts.Schedule.Reset()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
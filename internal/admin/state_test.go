@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"rudeserver/internal/ratelimit"
+	"rudeserver/internal/scenario"
+	"rudeserver/internal/sequence"
+)
+
+func TestLimiters(t *testing.T) {
+	limits := ratelimit.NewStore()
+	h := Handler(Options{RateLimit: limits})
+
+	sc := scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		Method:         "GET",
+		NormalizedPath: "/status/200",
+		RateLimit:      &scenario.RateLimit{Algorithm: scenario.RateToken, RPS: 1, Burst: 3},
+	}
+	for _, ip := range []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"} {
+		ratelimit.Check(limits, sc, ratelimit.Key(sc, ip), time.Now())
+	}
+
+	rec := do(t, h, http.MethodGet, "/admin/limiters?prefix=http|GET|/status/200|203.0.113.1", "")
+	var list struct {
+		Total int                 `json:"total"`
+		Items []ratelimit.KeyInfo `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || list.Total != 1 {
+		t.Fatalf("list = %s", rec.Body.String())
+	}
+	if item := list.Items[0]; item.Remaining != 1 || item.Burst != 3 || item.RPS != 1 || item.LastSeen == nil {
+		t.Fatalf("item = %+v", item)
+	}
+
+	key := "/admin/limiters/" + url.PathEscape(ratelimit.Key(sc, "203.0.113.2"))
+	if rec = do(t, h, http.MethodGet, key, ""); rec.Code != http.StatusOK {
+		t.Fatalf("get status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec = do(t, h, http.MethodDelete, key, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", rec.Code)
+	}
+	if rec = do(t, h, http.MethodGet, key, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get deleted status = %d", rec.Code)
+	}
+
+	rec = do(t, h, http.MethodDelete, "/admin/limiters?prefix=http|", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "{\"removed\":1}\n" {
+		t.Fatalf("reset = %d %s", rec.Code, rec.Body.String())
+	}
+	if d, _ := ratelimit.Check(limits, sc, ratelimit.Key(sc, "203.0.113.1"), time.Now()); d.Remaining != 2 {
+		t.Fatalf("after reset = %+v", d)
+	}
+}
+
+func TestResetState(t *testing.T) {
+	seqs := sequence.NewStore()
+	h := Handler(Options{RateLimit: ratelimit.NewStore(), Sequence: seqs})
+
+	sc := scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		Method:         "GET",
+		NormalizedPath: "/status/200",
+		Sequence:       &scenario.Sequence{Steps: []scenario.Step{{StatusCode: 503}, {StatusCode: 200}}},
+	}
+	sequence.Next(seqs, sc, "203.0.113.1")
+
+	if rec := do(t, h, http.MethodDelete, "/admin/state", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("reset status = %d", rec.Code)
+	}
+	if step, _ := sequence.Next(seqs, sc, "203.0.113.1"); step.StatusCode != 503 {
+		t.Fatalf("step after reset = %+v", step)
+	}
+	if rec := do(t, h, http.MethodGet, "/admin/state", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("get status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"rudeserver/internal/reqlog"
+	"rudeserver/internal/stub"
+)
+
+// verifyRequest asserts what the server received. Without Order it counts
+// the logged requests matching Request; with Order it checks that requests
+// matching each matcher arrived in that order. Exactly, AtLeast and AtMost
+// bound the count; with none of them at least one match is required.
+type verifyRequest struct {
+	Request stub.Matcher   `json:"request"`
+	Order   []stub.Matcher `json:"order"`
+	Since   time.Time      `json:"since"`
+	Until   time.Time      `json:"until"`
+	Within  string         `json:"within"`
+	Exactly *int           `json:"exactly"`
+	AtLeast *int           `json:"at_least"`
+	AtMost  *int           `json:"at_most"`
+}
+
+func handleVerify(w http.ResponseWriter, r *http.Request, logs *reqlog.Store) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, "POST")
+		return
+	}
+	var req verifyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if err := req.compile(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logged := logs.List()
+	entries := req.window(logged)
+	var out map[string]any
+	if len(req.Order) > 0 {
+		out = verifyOrder(req.Order, entries)
+	} else {
+		ids := []int64{}
+		for _, e := range entries {
+			if _, ok := req.Request.Match(entryRequest(e)); ok {
+				ids = append(ids, e.ID)
+			}
+		}
+		out = map[string]any{
+			"ok":    req.countOK(len(ids)),
+			"count": len(ids),
+			"ids":   ids,
+		}
+	}
+	if req.rolledOver(logged, logs.Total()) {
+		out["rolled_over"] = true
+	}
+	if req.truncated(entries) {
+		out["truncated"] = true
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// rolledOver reports whether the log has dropped requests that may fall
+// inside the time window, so counts and order may be wrong. logged is
+// newest first.
+func (v *verifyRequest) rolledOver(logged []reqlog.Entry, total int64) bool {
+	if total <= int64(len(logged)) {
+		return false
+	}
+	return len(logged) == 0 || v.Since.IsZero() || !logged[len(logged)-1].Timestamp.Before(v.Since)
+}
+
+// truncated reports whether body patterns were checked against a body the
+// log only holds part of.
+func (v *verifyRequest) truncated(entries []reqlog.Entry) bool {
+	bodies := len(v.Request.Body) > 0
+	for _, m := range v.Order {
+		bodies = bodies || len(m.Body) > 0
+	}
+	if !bodies {
+		return false
+	}
+	return slices.ContainsFunc(entries, func(e reqlog.Entry) bool { return e.ReqTruncated })
+}
+
+func (v *verifyRequest) compile() error {
+	if v.Within != "" {
+		within, err := time.ParseDuration(v.Within)
+		if err != nil || within <= 0 {
+			return fmt.Errorf("invalid within")
+		}
+		v.Since = time.Now().Add(-within)
+	}
+	if len(v.Order) > 0 && (v.Exactly != nil || v.AtLeast != nil || v.AtMost != nil) {
+		return fmt.Errorf("order cannot be combined with counts")
+	}
+	if v.Exactly != nil && (v.AtLeast != nil || v.AtMost != nil) {
+		return fmt.Errorf("exactly cannot be combined with at_least or at_most")
+	}
+	if err := v.Request.Compile(); err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	for i := range v.Order {
+		if err := v.Order[i].Compile(); err != nil {
+			return fmt.Errorf("order %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// window returns the entries inside the time window, oldest first.
+func (v *verifyRequest) window(entries []reqlog.Entry) []reqlog.Entry {
+	out := make([]reqlog.Entry, 0, len(entries))
+	for _, e := range slices.Backward(entries) {
+		if !v.Since.IsZero() && e.Timestamp.Before(v.Since) {
+			continue
+		}
+		if !v.Until.IsZero() && e.Timestamp.After(v.Until) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (v *verifyRequest) countOK(n int) bool {
+	switch {
+	case v.Exactly != nil:
+		return n == *v.Exactly
+	case v.AtLeast == nil && v.AtMost == nil:
+		return n > 0
+	}
+	if v.AtLeast != nil && n < *v.AtLeast {
+		return false
+	}
+	if v.AtMost != nil && n > *v.AtMost {
+		return false
+	}
+	return true
+}
+
+// verifyOrder finds the earliest entries matching each matcher in turn.
+// failed_at is the index of the first matcher with no match after the
+// previous one.
+func verifyOrder(order []stub.Matcher, entries []reqlog.Entry) map[string]any {
+	ids := []int64{}
+	next := 0
+	for i := range order {
+		found := false
+		for next < len(entries) {
+			e := entries[next]
+			next++
+			if _, ok := order[i].Match(entryRequest(e)); ok {
+				ids = append(ids, e.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return map[string]any{"ok": false, "ids": ids, "failed_at": i}
+		}
+	}
+	return map[string]any{"ok": true, "ids": ids}
+}
+
+func entryRequest(e reqlog.Entry) stub.Request {
+	query, _ := url.ParseQuery(e.Query)
+	return stub.Request{
+		Method: e.Method,
+		Path:   e.Path,
+		Query:  query,
+		Header: http.Header(e.ReqHeaders),
+		Body:   e.ReqBody,
+	}
+}
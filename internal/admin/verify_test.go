@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"rudeserver/internal/reqlog"
+)
+
+func verifyLog() *reqlog.Store {
+	logs := reqlog.NewStore(100)
+	old := time.Now().Add(-time.Hour)
+	logs.Add(reqlog.Entry{Timestamp: old, Method: "GET", Path: "/v1/users"})
+	logs.Add(reqlog.Entry{Method: "POST", Path: "/v1/login", ReqBody: []byte(`{"user":"bob"}`)})
+	for range 3 {
+		logs.Add(reqlog.Entry{
+			Method:     "GET",
+			Path:       "/v1/users",
+			Query:      "page=2",
+			ReqHeaders: map[string][]string{"Authorization": {"Bearer t"}},
+		})
+	}
+	logs.Add(reqlog.Entry{Method: "POST", Path: "/v1/logout"})
+	return logs
+}
+
+func verify(t *testing.T, logs *reqlog.Store, body string) (int, map[string]any) {
+	t.Helper()
+	rec := do(t, Handler(Options{Log: logs}), http.MethodPost, "/admin/verify", body)
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec.Code, out
+}
+
+func TestVerifyCounts(t *testing.T) {
+	logs := verifyLog()
+
+	cases := []struct {
+		body  string
+		ok    bool
+		count float64
+	}{
+		{`{"request":{"method":"GET","path":"/v1/users"}}`, true, 4},
+		{`{"request":{"path":"/v1/users"},"within":"1m","exactly":3}`, true, 3},
+		{`{"request":{"path":"/v1/users","query":{"page":{"equal_to":"2"}},"headers":{"Authorization":{"contains":"Bearer"}}},"at_least":4}`, false, 3},
+		{`{"request":{"path":"/v1/users"},"within":"1m","at_least":2,"at_most":3}`, true, 3},
+		{`{"request":{"body":[{"json_path":"$.user","equal_to":"bob"}]},"exactly":1}`, true, 1},
+		{`{"request":{"path":"/v1/orders"}}`, false, 0},
+		{`{"request":{"path":"/v1/orders"},"exactly":0}`, true, 0},
+	}
+	for _, tc := range cases {
+		code, out := verify(t, logs, tc.body)
+		if code != http.StatusOK || out["ok"] != tc.ok || out["count"] != tc.count {
+			t.Fatalf("%s: status = %d, out = %v", tc.body, code, out)
+		}
+	}
+}
+
+func TestVerifyOrder(t *testing.T) {
+	logs := verifyLog()
+
+	_, out := verify(t, logs, `{"order":[{"path":"/v1/login"},{"path":"/v1/users"},{"path":"/v1/logout"}]}`)
+	if out["ok"] != true || len(out["ids"].([]any)) != 3 {
+		t.Fatalf("out = %v", out)
+	}
+
+	_, out = verify(t, logs, `{"order":[{"path":"/v1/logout"},{"path":"/v1/login"}]}`)
+	if out["ok"] != false || out["failed_at"] != float64(1) {
+		t.Fatalf("out = %v", out)
+	}
+
+	_, out = verify(t, logs, `{"order":[{"path":"/v1/users"},{"path":"/v1/login"}],"within":"1m"}`)
+	if out["ok"] != false {
+		t.Fatalf("window should exclude the old request: %v", out)
+	}
+}
+
+func TestVerifyRejectsInvalidInput(t *testing.T) {
+	logs := verifyLog()
+	for _, body := range []string{
+		`{"within":"soon"}`,
+		`{"exactly":1,"at_least":1}`,
+		`{"order":[{"path":"/a"}],"exactly":1}`,
+		`{"request":{"path_pattern":"("}}`,
+	} {
+		if code, _ := verify(t, logs, body); code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d", body, code)
+		}
+	}
+	if rec := do(t, Handler(Options{Log: logs}), http.MethodGet, "/admin/verify", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d", rec.Code)
+	}
+}
+
+func TestVerifyFlagsIncompleteLog(t *testing.T) {
+	logs := reqlog.NewStore(2)
+	for range 3 {
+		logs.Add(reqlog.Entry{Method: "POST", Path: "/v1/orders", ReqBody: []byte(`{"id":`), ReqTruncated: true})
+	}
+
+	_, out := verify(t, logs, `{"request":{"path":"/v1/orders"},"exactly":3}`)
+	if out["ok"] != false || out["rolled_over"] != true || out["truncated"] != nil {
+		t.Fatalf("out = %v", out)
+	}
+	_, out = verify(t, logs, `{"request":{"path":"/v1/orders","body":[{"contains":"id"}]},"since":"2000-01-01T00:00:00Z"}`)
+	if out["rolled_over"] != true || out["truncated"] != true {
+		t.Fatalf("out = %v", out)
+	}
+	if _, out = verify(t, verifyLog(), `{"request":{"path":"/v1/users"}}`); out["rolled_over"] != nil {
+		t.Fatalf("out = %v", out)
+	}
+}
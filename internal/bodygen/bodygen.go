@@ -0,0 +1,127 @@
+package bodygen
+
+import (
+	"io"
+	"math/rand/v2"
+	"strconv"
+
+	"rudeserver/internal/scenario"
+)
+
+const defaultPattern = "x"
+
+// Reader returns the generated body described by gen. Repeat fills cycle
+// through pattern; random fills are reproducible when seed is set.
+func Reader(gen scenario.Generator, pattern string, seed *int64) io.Reader {
+	var r io.Reader
+	switch gen.Kind {
+	case scenario.GenJSON:
+		return &jsonReader{target: gen.Size}
+	case scenario.GenBytes:
+		if gen.Fill == scenario.FillRandom {
+			var src rand.Source
+			if seed != nil {
+				src = rand.NewPCG(uint64(*seed), 0)
+			} else {
+				src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+			}
+			r = &randomReader{rng: rand.New(src)}
+		} else {
+			if pattern == "" {
+				pattern = defaultPattern
+			}
+			r = &repeatReader{pattern: []byte(pattern)}
+		}
+	}
+
+	if gen.Size < 0 {
+		return r
+	}
+	return io.LimitReader(r, gen.Size)
+}
+
+type repeatReader struct {
+	pattern []byte
+	off     int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.pattern[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.pattern)
+	}
+	return n, nil
+}
+
+type randomReader struct {
+	rng *rand.Rand
+}
+
+func (r *randomReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r.rng.Uint32())
+	}
+	return len(p), nil
+}
+
+const jsonFiller = "abcdefghijklmnopqrstuvwxyz0123456789abcdefghijkl"
+
+// jsonReader streams {"items":[{"id":0,"data":"..."},...]} until the
+// document is about target bytes long.
+type jsonReader struct {
+	target  int64
+	emitted int64
+	nextID  int
+	buf     []byte
+	state   int
+}
+
+const (
+	jsonOpen = iota
+	jsonItems
+	jsonClose
+	jsonDone
+)
+
+func (j *jsonReader) Read(p []byte) (int, error) {
+	for len(j.buf) == 0 {
+		switch j.state {
+		case jsonOpen:
+			j.buf = []byte(`{"items":[`)
+			j.state = jsonItems
+		case jsonItems:
+			item := j.item()
+			if j.emitted+int64(len(item))+2 > j.target {
+				j.state = jsonClose
+				continue
+			}
+			j.buf = item
+			j.nextID++
+		case jsonClose:
+			j.buf = []byte(`]}`)
+			j.state = jsonDone
+		case jsonDone:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, j.buf)
+	j.buf = j.buf[n:]
+	j.emitted += int64(n)
+	return n, nil
+}
+
+func (j *jsonReader) item() []byte {
+	item := make([]byte, 0, len(jsonFiller)+32)
+	if j.nextID > 0 {
+		item = append(item, ',')
+	}
+	item = append(item, `{"id":`...)
+	item = strconv.AppendInt(item, int64(j.nextID), 10)
+	item = append(item, `,"data":"`...)
+	item = append(item, jsonFiller...)
+	item = append(item, `"}`...)
+	return item
+}
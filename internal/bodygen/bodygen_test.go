@@ -0,0 +1,59 @@
+package bodygen
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"rudeserver/internal/scenario"
+)
+
+func TestReaderRepeatsPattern(t *testing.T) {
+	gen := scenario.Generator{Kind: scenario.GenBytes, Size: 7, Fill: scenario.FillRepeat}
+	got, err := io.ReadAll(Reader(gen, "abc", nil))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != "abcabca" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+func TestReaderRandomIsSeeded(t *testing.T) {
+	gen := scenario.Generator{Kind: scenario.GenBytes, Size: 1024, Fill: scenario.FillRandom}
+	seed := int64(9)
+
+	first, _ := io.ReadAll(Reader(gen, "", &seed))
+	second, _ := io.ReadAll(Reader(gen, "", &seed))
+	if len(first) != 1024 || !bytes.Equal(first, second) {
+		t.Fatalf("random bodies differ or have wrong size (%d)", len(first))
+	}
+}
+
+func TestReaderEndless(t *testing.T) {
+	gen := scenario.Generator{Kind: scenario.GenBytes, Size: -1, Fill: scenario.FillRepeat}
+	got, err := io.ReadAll(io.LimitReader(Reader(gen, "", nil), 1<<20))
+	if err != nil || len(got) != 1<<20 {
+		t.Fatalf("len = %d, err = %v", len(got), err)
+	}
+}
+
+func TestReaderJSON(t *testing.T) {
+	for _, size := range []int64{0, 100, 64 * 1024} {
+		gen := scenario.Generator{Kind: scenario.GenJSON, Size: size}
+		got, err := io.ReadAll(Reader(gen, "", nil))
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var doc struct {
+			Items []map[string]any `json:"items"`
+		}
+		if err := json.Unmarshal(got, &doc); err != nil {
+			t.Fatalf("size %d: invalid json: %v", size, err)
+		}
+		if int64(len(got)) > size+100 || (size > 1024 && int64(len(got)) < size-100) {
+			t.Fatalf("size %d: got %d bytes", size, len(got))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package capacity
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+// Store keeps one worker pool per simulated backend. A pool is dropped
+// once no request holds or waits for one of its workers.
+type Store struct {
+	mu    sync.Mutex
+	pools map[string]*pool
+}
+
+type pool struct {
+	slots chan struct{}
+	// users counts requests holding or waiting for a worker. It is
+	// guarded by the store's mutex.
+	users int
+
+	mu      sync.Mutex
+	waiting int
+}
+
+func NewStore() *Store {
+	return &Store{
+		pools: make(map[string]*pool),
+	}
+}
+
+// Key identifies a simulated backend. Clients share it, since the point
+// is the load they put on it together.
+func Key(sc scenario.Scenario) string {
+	c := sc.Capacity
+	return string(sc.Protocol) + "|" + sc.NormalizedPath + "|" + strconv.Itoa(c.Workers) + "|" + strconv.Itoa(c.Queue)
+}
+
+// Acquire takes a worker, waiting in the queue while all are busy. It
+// returns the release func and how many workers are busy including this
+// one. ok is false when the queue is full or ctx ends while waiting.
+func Acquire(store *Store, ctx context.Context, sc scenario.Scenario) (func(), int, bool) {
+	key := Key(sc)
+	p := store.enter(key, sc.Capacity.Workers)
+	release := func() {
+		<-p.slots
+		store.leave(key, p)
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return release, len(p.slots), true
+	default:
+	}
+
+	p.mu.Lock()
+	if p.waiting >= sc.Capacity.Queue {
+		p.mu.Unlock()
+		store.leave(key, p)
+		return nil, 0, false
+	}
+	p.waiting++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case p.slots <- struct{}{}:
+		return release, len(p.slots), true
+	case <-ctx.Done():
+		store.leave(key, p)
+		return nil, 0, false
+	}
+}
+
+// Stretch scales the service time d by contention: it is unchanged with one
+// worker busy and grows by a factor of 1 + Contention with all of them busy.
+// The result saturates at the longest representable duration.
+func Stretch(sc scenario.Scenario, d time.Duration, busy int) time.Duration {
+	c := sc.Capacity
+	if c == nil || c.Contention == 0 || busy <= 1 || d <= 0 {
+		return d
+	}
+	load := min(float64(busy-1)/float64(max(c.Workers-1, 1)), 1)
+	stretched := float64(d) * (1 + c.Contention*load)
+	if stretched >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(stretched)
+}
+
+// enter returns the key's pool, counting the caller as one of its users.
+func (s *Store) enter(key string, workers int) *pool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pools[key]
+	if !ok {
+		p = &pool{slots: make(chan struct{}, workers)}
+		s.pools[key] = p
+	}
+	p.users++
+	return p
+}
+
+// leave drops the caller from the pool's users, and the pool from the
+// store once it has none.
+func (s *Store) leave(key string, p *pool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.users--
+	if p.users == 0 && s.pools[key] == p {
+		delete(s.pools, key)
+	}
+}
@@ -0,0 +1,136 @@
+package capacity
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+func backend(workers int, queue int) scenario.Scenario {
+	return scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		NormalizedPath: "/orders",
+		Capacity:       &scenario.Capacity{Workers: workers, Queue: queue, Status: 503},
+	}
+}
+
+func TestAcquireQueuesThenRejects(t *testing.T) {
+	store := NewStore()
+	sc := backend(2, 1)
+	ctx := context.Background()
+
+	release1, busy, ok := Acquire(store, ctx, sc)
+	if !ok || busy != 1 {
+		t.Fatalf("first acquire = %d, %v", busy, ok)
+	}
+	release2, busy, ok := Acquire(store, ctx, sc)
+	if !ok || busy != 2 {
+		t.Fatalf("second acquire = %d, %v", busy, ok)
+	}
+
+	queued := make(chan bool)
+	go func() {
+		release, _, ok := Acquire(store, ctx, sc)
+		if ok {
+			release()
+		}
+		queued <- ok
+	}()
+
+	// Wait for the third request to take the only queue slot.
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		p := store.pools[Key(sc)]
+		store.mu.Unlock()
+		p.mu.Lock()
+		waiting := p.waiting
+		p.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("request was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, _, ok := Acquire(store, ctx, sc); ok {
+		t.Fatal("fourth request should be rejected with a full queue")
+	}
+
+	release1()
+	if !<-queued {
+		t.Fatal("queued request should get the freed worker")
+	}
+	release2()
+}
+
+func TestAcquireCancelledWhileQueued(t *testing.T) {
+	store := NewStore()
+	sc := backend(1, 5)
+	release, _, _ := Acquire(store, context.Background(), sc)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, ok := Acquire(store, ctx, sc); ok {
+		t.Fatal("acquire should give up when the context ends")
+	}
+}
+
+func TestBackendsAreSeparate(t *testing.T) {
+	store := NewStore()
+	a := backend(1, 0)
+	b := backend(1, 0)
+	b.NormalizedPath = "/users"
+
+	release, _, _ := Acquire(store, context.Background(), a)
+	defer release()
+	if _, _, ok := Acquire(store, context.Background(), b); !ok {
+		t.Fatal("other path should have its own workers")
+	}
+}
+
+func TestStretch(t *testing.T) {
+	sc := backend(5, 0)
+	sc.Capacity.Contention = 2
+	cases := map[int]time.Duration{1: 100 * time.Millisecond, 3: 200 * time.Millisecond, 5: 300 * time.Millisecond}
+	for busy, want := range cases {
+		if got := Stretch(sc, 100*time.Millisecond, busy); got != want {
+			t.Fatalf("busy %d: stretch = %v, want %v", busy, got, want)
+		}
+	}
+	if got := Stretch(backend(5, 0), time.Second, 5); got != time.Second {
+		t.Fatalf("no contention: stretch = %v", got)
+	}
+}
+
+func TestStretchSaturates(t *testing.T) {
+	sc := backend(2, 0)
+	sc.Capacity.Contention = 100
+	if got := Stretch(sc, time.Duration(math.MaxInt64/2), 2); got != math.MaxInt64 {
+		t.Fatalf("stretch = %v, want the longest duration", got)
+	}
+}
+
+func TestIdlePoolsAreDropped(t *testing.T) {
+	store := NewStore()
+	sc := backend(1, 0)
+
+	release, _, _ := Acquire(store, context.Background(), sc)
+	if _, _, ok := Acquire(store, context.Background(), sc); ok {
+		t.Fatal("second acquire should be rejected")
+	}
+	release()
+
+	store.mu.Lock()
+	n := len(store.pools)
+	store.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("pools = %d, want 0 once idle", n)
+	}
+}
@@ -0,0 +1,140 @@
+package chaos
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"rudeserver/internal/ratelimit"
+	"rudeserver/internal/scenario"
+)
+
+// Stream is a goroutine-safe random source.
+type Stream struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newStream(seed1, seed2 uint64) *Stream {
+	return &Stream{rng: rand.New(rand.NewPCG(seed1, seed2))}
+}
+
+func (s *Stream) Float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64()
+}
+
+func (s *Stream) NormFloat64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.NormFloat64()
+}
+
+func (s *Stream) IntN(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.IntN(n)
+}
+
+// Store keeps one seeded stream per limiter-style key and seed, so the Nth
+// call on a key sees the same random values on every run.
+type Store struct {
+	mu      sync.Mutex
+	streams map[string]*Stream
+	used    map[string]time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		streams: make(map[string]*Stream),
+		used:    make(map[string]time.Time),
+	}
+}
+
+// Seeded keys draw failures and delays from separate streams, so adding or
+// removing a delay distribution does not shift the failure outcomes.
+const (
+	failStream  = 0
+	delayStream = 1
+)
+
+// StreamFor returns the key's seeded failure stream, or a fresh unseeded
+// stream when the scenario has no seed.
+func StreamFor(store *Store, sc scenario.Scenario, clientIP string) *Stream {
+	return streamFor(store, sc, clientIP, failStream)
+}
+
+// DelayStreamFor is StreamFor for sampling delay distributions.
+func DelayStreamFor(store *Store, sc scenario.Scenario, clientIP string) *Stream {
+	return streamFor(store, sc, clientIP, delayStream)
+}
+
+func streamFor(store *Store, sc scenario.Scenario, clientIP string, n uint64) *Stream {
+	if sc.Seed == nil {
+		return newStream(rand.Uint64(), rand.Uint64())
+	}
+
+	key := ratelimit.Key(sc, clientIP) + "|" + strconv.FormatInt(*sc.Seed, 10) + "|" + strconv.FormatUint(n, 10)
+	return store.getStream(key, uint64(*sc.Seed), n)
+}
+
+// Fail rolls the scenario's failure probability and, on failure, picks a
+// status from the weighted distribution.
+func Fail(sc scenario.Scenario, stream *Stream) (int, bool) {
+	if sc.Failure == nil || len(sc.Failure.Statuses) == 0 {
+		return 0, false
+	}
+	if stream.Float64() >= sc.Failure.Probability {
+		return 0, false
+	}
+
+	total := 0
+	for _, ws := range sc.Failure.Statuses {
+		total += ws.Weight
+	}
+	n := stream.IntN(total)
+	for _, ws := range sc.Failure.Statuses {
+		if n < ws.Weight {
+			return ws.StatusCode, true
+		}
+		n -= ws.Weight
+	}
+	return sc.Failure.Statuses[len(sc.Failure.Statuses)-1].StatusCode, true
+}
+
+func (s *Store) getStream(key string, seed uint64, n uint64) *Stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.used[key] = time.Now()
+	if stream, ok := s.streams[key]; ok {
+		return stream
+	}
+
+	stream := newStream(seed, n)
+	s.streams[key] = stream
+	return stream
+}
+
+// Sweep drops the streams of keys unused for longer than ttl, so they
+// replay from the start.
+func (s *Store) Sweep(now time.Time, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, used := range s.used {
+		if now.Sub(used) > ttl {
+			delete(s.streams, key)
+			delete(s.used, key)
+		}
+	}
+}
+
+// Reset drops every seeded stream, so seeded keys replay from the start.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.streams)
+	clear(s.used)
+}
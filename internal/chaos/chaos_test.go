@@ -0,0 +1,78 @@
+package chaos
+
+import (
+	"testing"
+
+	"rudeserver/internal/scenario"
+)
+
+func chaosScenario(prob float64, seed int64, statuses ...scenario.WeightedStatus) scenario.Scenario {
+	if len(statuses) == 0 {
+		statuses = []scenario.WeightedStatus{{StatusCode: 500, Weight: 1}}
+	}
+	return scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		Method:         "GET",
+		NormalizedPath: "/flaky",
+		Failure:        &scenario.Failure{Probability: prob, Statuses: statuses},
+		Seed:           &seed,
+	}
+}
+
+func outcomes(store *Store, sc scenario.Scenario, n int) []int {
+	out := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		status, _ := Fail(sc, StreamFor(store, sc, "203.0.113.1"))
+		out = append(out, status)
+	}
+	return out
+}
+
+func TestSeededStreamIsReproducible(t *testing.T) {
+	sc := chaosScenario(0.5, 42,
+		scenario.WeightedStatus{StatusCode: 500, Weight: 2},
+		scenario.WeightedStatus{StatusCode: 503, Weight: 1},
+	)
+
+	first := outcomes(NewStore(), sc, 50)
+	second := outcomes(NewStore(), sc, 50)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("call %d: %d != %d", i, first[i], second[i])
+		}
+	}
+}
+
+func TestDelayStreamDoesNotShiftFailures(t *testing.T) {
+	sc := chaosScenario(0.5, 42)
+	want := outcomes(NewStore(), sc, 50)
+
+	store := NewStore()
+	for i := range want {
+		DelayStreamFor(store, sc, "203.0.113.1").Float64()
+		if status, _ := Fail(sc, StreamFor(store, sc, "203.0.113.1")); status != want[i] {
+			t.Fatalf("call %d: %d != %d with delays sampled", i, status, want[i])
+		}
+	}
+}
+
+func TestFailProbabilityBounds(t *testing.T) {
+	store := NewStore()
+
+	for _, status := range outcomes(store, chaosScenario(0, 1), 20) {
+		if status != 0 {
+			t.Fatalf("fail=0 produced %d", status)
+		}
+	}
+	for _, status := range outcomes(store, chaosScenario(1, 2), 20) {
+		if status != 500 {
+			t.Fatalf("fail=1 produced %d", status)
+		}
+	}
+}
+
+func TestFailWithoutFailure(t *testing.T) {
+	if _, ok := Fail(scenario.Scenario{}, StreamFor(NewStore(), scenario.Scenario{}, "")); ok {
+		t.Fatal("expected no failure")
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"rudeserver/internal/scenario"
+	"rudeserver/internal/stub"
+)
+
+// Config is the route configuration file. JSON files parse too, since
+// YAML is a superset of JSON. Stubs are matched before routes.
+type Config struct {
+	Routes []Route     `json:"routes" yaml:"routes"`
+	Stubs  []stub.Stub `json:"stubs" yaml:"stubs"`
+
+	stubs *stub.Store
+}
+
+// Route serves a scenario for requests matching its path pattern and methods.
+// Patterns match segment by segment; "{name}" captures one segment and a
+// trailing "{name...}" captures the rest of the path.
+type Route struct {
+	Name     string        `json:"name" yaml:"name"`
+	Path     string        `json:"path" yaml:"path"`
+	Methods  []string      `json:"methods" yaml:"methods"`
+	Response scenario.Spec `json:"response" yaml:"response"`
+
+	segments []string
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse rejects unknown keys, so a typo such as "stauts" fails the load
+// instead of quietly serving a default response.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	for i := range cfg.Routes {
+		route := &cfg.Routes[i]
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route-%d", i+1)
+		}
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("route %s: path must start with /", route.Name)
+		}
+		route.segments = strings.Split(strings.TrimPrefix(route.Path, "/"), "/")
+		for j, seg := range route.segments {
+			if strings.HasSuffix(seg, "...}") && j != len(route.segments)-1 {
+				return nil, fmt.Errorf("route %s: %s must be the last segment", route.Name, seg)
+			}
+		}
+		for j, method := range route.Methods {
+			route.Methods[j] = strings.ToUpper(method)
+		}
+		if _, err := scenario.FromSpec(route.Response, http.MethodGet, route.Path); err != nil {
+			return nil, fmt.Errorf("route %s: %w", route.Name, err)
+		}
+	}
+
+	cfg.stubs = stub.NewStore()
+	if err := cfg.stubs.Replace(cfg.Stubs); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Match returns the first route matching the request and its path parameters.
+func (c *Config) Match(method string, path string) (*Route, map[string]string, bool) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if !route.allows(method) {
+			continue
+		}
+		if params, ok := route.match(segments); ok {
+			return route, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (r *Route) allows(method string) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Route) match(segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, pattern := range r.segments {
+		name, isParam := strings.CutPrefix(pattern, "{")
+		name, _ = strings.CutSuffix(name, "}")
+		if isParam {
+			if rest, ok := strings.CutSuffix(name, "..."); ok {
+				if i > len(segments) {
+					return nil, false
+				}
+				params[rest] = strings.Join(segments[i:], "/")
+				return params, true
+			}
+		}
+
+		if i >= len(segments) {
+			return nil, false
+		}
+		if isParam {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[name] = segments[i]
+		} else if pattern != segments[i] {
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(r.segments)
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"testing"
+)
+
+const sampleConfig = `
+routes:
+  - name: get-order
+    path: /v1/orders/{id}
+    methods: [get]
+    response:
+      protocol: rest
+      status: 200
+      headers:
+        Content-Type: application/json
+      body: '{"id":"{{.Params.id}}"}'
+      delay: 10ms..20ms
+      rate_limit: {rps: 5, burst: 2}
+      params:
+        tmpl: "1"
+  - path: /files/{path...}
+    response:
+      status: 404
+`
+
+func TestParseAndMatch(t *testing.T) {
+	cfg, err := Parse([]byte(sampleConfig))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(cfg.Routes) != 2 || cfg.Routes[1].Name != "route-2" {
+		t.Fatalf("routes = %+v", cfg.Routes)
+	}
+
+	route, params, ok := cfg.Match("GET", "/v1/orders/42")
+	if !ok || route.Name != "get-order" || params["id"] != "42" {
+		t.Fatalf("match = %v, %v, %v", route, params, ok)
+	}
+
+	if _, _, ok := cfg.Match("POST", "/v1/orders/42"); ok {
+		t.Fatal("POST should not match a GET-only route")
+	}
+	if _, _, ok := cfg.Match("GET", "/v1/orders/42/items"); ok {
+		t.Fatal("extra segments should not match")
+	}
+	if _, _, ok := cfg.Match("GET", "/v1/orders/"); ok {
+		t.Fatal("empty segment should not match a parameter")
+	}
+
+	route, params, ok = cfg.Match("DELETE", "/files/a/b/c.txt")
+	if !ok || route.Name != "route-2" || params["path"] != "a/b/c.txt" {
+		t.Fatalf("match = %v, %v, %v", route, params, ok)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	cfg, err := Parse([]byte(`{"routes":[{"path":"/health","response":{"status":204}}]}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, _, ok := cfg.Match("GET", "/health"); !ok {
+		t.Fatal("expected match")
+	}
+}
+
+func TestParseRejectsInvalidRoutes(t *testing.T) {
+	cases := []string{
+		`routes: [{path: "relative"}]`,
+		`routes: [{path: "/a/{rest...}/b"}]`,
+		`routes: [{path: "/a", response: {delay: "soon"}}]`,
+		`routes: [{path: "/a", response: {protocol: "grpc"}}]`,
+		`routes: {`,
+		`routes: [{path: "/a", response: {stauts: 503}}]`,
+		`routes: [{path: "/a", response: {rate_limt: {rps: 1}}}]`,
+		`routes: [{path: "/a", response: {delay: {min: 1s, maks: 2s}}}]`,
+	}
+	for _, raw := range cases {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseStubs(t *testing.T) {
+	cfg, err := Parse([]byte(`
+stubs:
+  - name: slow-search
+    priority: 1
+    request:
+      method: GET
+      path_pattern: /search
+      query:
+        q: {contains: slow}
+    response:
+      status: 200
+      delay: 2s
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(cfg.Stubs) != 1 || cfg.Stubs[0].Request.Query["q"].Contains != "slow" {
+		t.Fatalf("stubs = %+v", cfg.Stubs)
+	}
+
+	if _, err := Parse([]byte(`stubs: [{request: {path_pattern: "("}}]`)); err == nil {
+		t.Fatal("expected error")
+	}
+}
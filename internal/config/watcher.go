@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+// Watcher holds the current configuration and reloads it when the file changes.
+type Watcher struct {
+	path    string
+	current atomic.Pointer[Config]
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher loads the file once; later reload failures keep the last good config.
+func NewWatcher(path string) (*Watcher, error) {
+	w := &Watcher{path: path}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Watcher) Config() *Config {
+	return w.current.Load()
+}
+
+// Run polls the file every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			if err := w.reload(); err != nil {
+				log.Printf("config reload error: %v", err)
+				continue
+			}
+			log.Printf("config reloaded from %s", w.path)
+		}
+	}
+}
+
+// Resolve serves configured stubs and routes ahead of the smart-URL parser.
+func (w *Watcher) Resolve(r *http.Request) (scenario.Scenario, bool, error) {
+	cfg := w.Config()
+	if sc, ok, err := cfg.stubs.Resolve(r); ok || err != nil {
+		return sc, ok, err
+	}
+
+	route, params, ok := cfg.Match(r.Method, r.URL.Path)
+	if !ok {
+		return scenario.Scenario{}, false, nil
+	}
+
+	// Keys use the route's pattern so /orders/1 and /orders/2 share its
+	// limiters; templates still see the concrete path.
+	sc, err := scenario.FromSpec(route.Response, r.Method, route.Path)
+	if err != nil {
+		return scenario.Scenario{}, true, err
+	}
+	sc.PathParams = params
+	return sc, true, nil
+}
+
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+func (w *Watcher) reload() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	cfg, err := Load(w.path)
+	if err != nil {
+		// Remember the broken version so it is not re-parsed every tick.
+		w.modTime, w.size = info.ModTime(), info.Size()
+		return err
+	}
+	w.current.Store(cfg)
+	w.modTime, w.size = info.ModTime(), info.Size()
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path string, body string, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+func TestWatcherResolvesRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeConfig(t, path, sampleConfig, time.Now())
+
+	w, err := NewWatcher(path)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+
+	sc, ok, err := w.Resolve(httptest.NewRequest(http.MethodGet, "/v1/orders/9", nil))
+	if err != nil || !ok {
+		t.Fatalf("resolve: ok = %v, err = %v", ok, err)
+	}
+	if sc.StatusCode != 200 || sc.PathParams["id"] != "9" || !sc.Template || sc.NormalizedPath != "/v1/orders/{id}" {
+		t.Fatalf("scenario = %+v", sc)
+	}
+
+	if _, ok, _ := w.Resolve(httptest.NewRequest(http.MethodGet, "/http/status/200", nil)); ok {
+		t.Fatal("smart URLs should fall through")
+	}
+}
+
+func TestWatcherResolvesStubsFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeConfig(t, path, sampleConfig+`
+stubs:
+  - request:
+      path: /v1/orders/13
+      headers:
+        X-Tenant: {equal_to: unlucky}
+    response:
+      status: 500
+`, time.Now())
+
+	w, err := NewWatcher(path)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/orders/13", nil)
+	r.Header.Set("X-Tenant", "unlucky")
+	if sc, _, _ := w.Resolve(r); sc.StatusCode != 500 {
+		t.Fatalf("stub status = %d", sc.StatusCode)
+	}
+	if sc, _, _ := w.Resolve(httptest.NewRequest(http.MethodGet, "/v1/orders/13", nil)); sc.StatusCode != 200 {
+		t.Fatalf("route status = %d", sc.StatusCode)
+	}
+}
+
+func TestWatcherReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	start := time.Now().Add(-time.Hour)
+	writeConfig(t, path, `routes: [{path: /health, response: {status: 200}}]`, start)
+
+	w, err := NewWatcher(path)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx, 10*time.Millisecond)
+
+	// A broken file keeps the last good config.
+	writeConfig(t, path, `routes: {`, start.Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if _, _, ok := w.Config().Match("GET", "/health"); !ok {
+		t.Fatal("broken reload should keep previous routes")
+	}
+
+	writeConfig(t, path, `routes: [{path: /ready, response: {status: 503}}]`, start.Add(2*time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, _, ok := w.Config().Match("GET", "/ready"); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("config was not reloaded")
+}
+
+func TestNewWatcherMissingFile(t *testing.T) {
+	if _, err := NewWatcher(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,56 @@
+package delay
+
+import (
+	"math"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+// Source supplies the random values used to sample a distribution.
+type Source interface {
+	Float64() float64
+	NormFloat64() float64
+}
+
+// Sample draws a delay from the distribution. Negative samples are clamped to zero.
+func Sample(dist scenario.Distribution, src Source) time.Duration {
+	var d float64
+	switch dist.Kind {
+	case scenario.DistUniform:
+		d = float64(dist.Min) + src.Float64()*float64(dist.Max-dist.Min)
+	case scenario.DistNormal:
+		d = float64(dist.Mean) + src.NormFloat64()*float64(dist.StdDev)
+	case scenario.DistLogNormal:
+		// Convert the desired mean/stddev into the parameters of the
+		// underlying normal distribution.
+		mean, stddev := float64(dist.Mean), float64(dist.StdDev)
+		sigma2 := math.Log(1 + (stddev*stddev)/(mean*mean))
+		mu := math.Log(mean) - sigma2/2
+		d = math.Exp(mu + math.Sqrt(sigma2)*src.NormFloat64())
+	case scenario.DistPercentile:
+		d = samplePercentile(dist.Percentiles, src.Float64()*100)
+	}
+
+	if d < 0 || math.IsNaN(d) {
+		return 0
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// samplePercentile interpolates linearly between the given percentiles,
+// starting from zero at p0 and holding the highest value past the last one.
+func samplePercentile(points []scenario.Percentile, rank float64) float64 {
+	prevRank, prevValue := 0.0, 0.0
+	for _, p := range points {
+		if rank < p.Rank {
+			frac := (rank - prevRank) / (p.Rank - prevRank)
+			return prevValue + frac*(float64(p.Value)-prevValue)
+		}
+		prevRank, prevValue = p.Rank, float64(p.Value)
+	}
+	return prevValue
+}
@@ -0,0 +1,73 @@
+package delay
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+func TestSampleUniformStaysInRange(t *testing.T) {
+	dist := scenario.Distribution{Kind: scenario.DistUniform, Min: 100 * time.Millisecond, Max: 500 * time.Millisecond}
+	src := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 1000; i++ {
+		d := Sample(dist, src)
+		if d < dist.Min || d > dist.Max {
+			t.Fatalf("sample = %v", d)
+		}
+	}
+}
+
+func TestSampleNormalClampsAtZero(t *testing.T) {
+	dist := scenario.Distribution{Kind: scenario.DistNormal, Mean: time.Millisecond, StdDev: time.Second}
+	src := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 1000; i++ {
+		if d := Sample(dist, src); d < 0 {
+			t.Fatalf("sample = %v", d)
+		}
+	}
+}
+
+func TestSampleLogNormalMean(t *testing.T) {
+	dist := scenario.Distribution{Kind: scenario.DistLogNormal, Mean: 200 * time.Millisecond, StdDev: 100 * time.Millisecond}
+	src := rand.New(rand.NewPCG(1, 2))
+
+	var total time.Duration
+	const n = 20000
+	for i := 0; i < n; i++ {
+		total += Sample(dist, src)
+	}
+	mean := total / n
+	if mean < 190*time.Millisecond || mean > 210*time.Millisecond {
+		t.Fatalf("mean = %v", mean)
+	}
+}
+
+func TestSamplePercentiles(t *testing.T) {
+	dist := scenario.Distribution{
+		Kind: scenario.DistPercentile,
+		Percentiles: []scenario.Percentile{
+			{Rank: 50, Value: 50 * time.Millisecond},
+			{Rank: 99, Value: 2 * time.Second},
+		},
+	}
+	src := rand.New(rand.NewPCG(1, 2))
+
+	below := 0
+	const n = 10000
+	for i := 0; i < n; i++ {
+		d := Sample(dist, src)
+		if d > 2*time.Second {
+			t.Fatalf("sample = %v", d)
+		}
+		if d <= 50*time.Millisecond {
+			below++
+		}
+	}
+	if below < n*45/100 || below > n*55/100 {
+		t.Fatalf("samples at or below p50 = %d of %d", below, n)
+	}
+}
@@ -1,19 +1,39 @@
 package delay
 
 import (
+	"context"
 	"net/http"
 	"time"
 )
 
-// Wrap sleeps for the given delay before invoking the next handler.
+// Wrap sleeps for the given delay before invoking the next handler. If the
+// client goes away while waiting, the next handler is never invoked.
 func Wrap(next http.Handler, delay time.Duration) http.Handler {
 	if delay <= 0 {
 		return next
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time.Sleep(delay)
+		if !Sleep(r.Context(), delay) {
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// Sleep waits for d or until ctx is done. It reports whether the full delay elapsed.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
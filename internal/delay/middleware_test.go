@@ -1,6 +1,7 @@
 package delay
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -24,3 +25,25 @@ func TestWrapAppliesDelay(t *testing.T) {
 		t.Fatalf("elapsed = %v, want >= %v", elapsed, delay)
 	}
 }
+
+func TestWrapAbortsOnCancel(t *testing.T) {
+	called := false
+	h := Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+	if called {
+		t.Fatal("next handler should not run after cancel")
+	}
+}
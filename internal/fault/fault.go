@@ -0,0 +1,79 @@
+package fault
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"rudeserver/internal/scenario"
+)
+
+// Inject hijacks the connection and misbehaves at the transport level
+// according to sc.Fault instead of writing a well-formed response.
+func Inject(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) {
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "fault injection unsupported", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	switch sc.Fault {
+	case scenario.FaultReset:
+		// A zero linger makes Close send RST instead of FIN.
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			_ = tcp.SetLinger(0)
+		}
+	case scenario.FaultClose:
+	case scenario.FaultHang:
+		// Never answer; hold the connection until the client gives up.
+		_, _ = io.Copy(io.Discard, buf)
+	case scenario.FaultHeaders:
+		writeHead(buf, sc, promised(responseBody(sc)))
+		_ = buf.Flush()
+	case scenario.FaultTruncate:
+		body := responseBody(sc)
+		writeHead(buf, sc, promised(body))
+		_, _ = buf.WriteString(body[:len(body)/2])
+		_ = buf.Flush()
+	}
+}
+
+// promised is the Content-Length to announce for body. It is at least one
+// byte, so even an empty body ends up short and the fault is never a
+// well-formed response.
+func promised(body string) int {
+	return max(len(body), 1)
+}
+
+func responseBody(sc scenario.Scenario) string {
+	if sc.Body != "" {
+		return sc.Body
+	}
+	return http.StatusText(status(sc))
+}
+
+func status(sc scenario.Scenario) int {
+	if sc.StatusCode == 0 {
+		return http.StatusOK
+	}
+	return sc.StatusCode
+}
+
+// writeHead writes a raw HTTP/1.1 status line and headers announcing
+// contentLength bytes of body.
+func writeHead(buf *bufio.ReadWriter, sc scenario.Scenario, contentLength int) {
+	code := status(sc)
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", code, http.StatusText(code))
+	headers := sc.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set("Content-Length", strconv.Itoa(contentLength))
+	headers.Set("Connection", "close")
+	_ = headers.Write(buf)
+	_, _ = buf.WriteString("\r\n")
+}
@@ -0,0 +1,132 @@
+package fault
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+func faultServer(t *testing.T, sc scenario.Scenario) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Inject(w, r, sc)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func rawGet(t *testing.T, srv *httptest.Server) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n"); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	return conn
+}
+
+func TestInjectClose(t *testing.T) {
+	conn := rawGet(t, faultServer(t, scenario.Scenario{Fault: scenario.FaultClose}))
+
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("got %q, want nothing", got)
+	}
+}
+
+func TestInjectReset(t *testing.T) {
+	conn := rawGet(t, faultServer(t, scenario.Scenario{Fault: scenario.FaultReset}))
+
+	_, err := io.ReadAll(conn)
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("err = %v, want connection reset", err)
+	}
+}
+
+func TestInjectHang(t *testing.T) {
+	conn := rawGet(t, faultServer(t, scenario.Scenario{Fault: scenario.FaultHang}))
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	n, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if n != 0 || !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("n = %d, err = %v, want timeout", n, err)
+	}
+}
+
+func TestInjectHeadersThenClose(t *testing.T) {
+	sc := scenario.Scenario{Fault: scenario.FaultHeaders, StatusCode: 202, Body: "hello", Headers: http.Header{"X-Test": {"1"}}}
+	conn := rawGet(t, faultServer(t, sc))
+
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if res.StatusCode != 202 || res.Header.Get("X-Test") != "1" || res.ContentLength != 5 {
+		t.Fatalf("status = %d, headers = %v", res.StatusCode, res.Header)
+	}
+	if _, err := io.ReadAll(res.Body); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("body err = %v, want unexpected EOF", err)
+	}
+}
+
+func TestInjectTruncate(t *testing.T) {
+	sc := scenario.Scenario{Fault: scenario.FaultTruncate, Body: "0123456789"}
+	srv := faultServer(t, sc)
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer res.Body.Close()
+
+	got, err := io.ReadAll(res.Body)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("err = %v, want unexpected EOF", err)
+	}
+	if !strings.HasPrefix(sc.Body, string(got)) || len(got) != 5 {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+func TestInjectEmptyBodyStaysShort(t *testing.T) {
+	for _, f := range []scenario.Fault{scenario.FaultHeaders, scenario.FaultTruncate} {
+		// 299 has no status text, so there is no body to fall back on.
+		conn := rawGet(t, faultServer(t, scenario.Scenario{Fault: f, StatusCode: 299}))
+
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("%s: read response: %v", f, err)
+		}
+		if res.ContentLength < 1 {
+			t.Fatalf("%s: content length = %d", f, res.ContentLength)
+		}
+		if _, err := io.ReadAll(res.Body); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("%s: body err = %v, want unexpected EOF", f, err)
+		}
+	}
+}
+
+func TestInjectWithoutHijacker(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Inject(rec, httptest.NewRequest(http.MethodGet, "/", nil), scenario.Scenario{Fault: scenario.FaultClose})
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
@@ -1,39 +1,171 @@
 package httpserver
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
+	"rudeserver/internal/capacity"
+	"rudeserver/internal/chaos"
 	"rudeserver/internal/delay"
+	"rudeserver/internal/fault"
 	"rudeserver/internal/ip"
 	"rudeserver/internal/protocol"
 	"rudeserver/internal/ratelimit"
 	"rudeserver/internal/scenario"
+	"rudeserver/internal/schedule"
+	"rudeserver/internal/sequence"
+	"rudeserver/internal/throttle"
+	"rudeserver/internal/tmpl"
 )
 
+// Resolver maps a request to a scenario ahead of the smart-URL parser.
+// ok is false when the resolver has nothing for the request.
+type Resolver interface {
+	Resolve(r *http.Request) (sc scenario.Scenario, ok bool, err error)
+}
+
+// Options holds the state shared by every request served by the router.
+// Nil stores are replaced with fresh ones. Resolvers are tried in order
+// before falling back to the smart-URL parser.
+type Options struct {
+	RateLimit *ratelimit.Store
+	Sequence  *sequence.Store
+	Chaos     *chaos.Store
+	Schedule  *schedule.Store
+	Capacity  *capacity.Store
+	Resolvers []Resolver
+}
+
 func NewRouter(store *ratelimit.Store) http.Handler {
-	if store == nil {
-		store = ratelimit.NewStore()
+	return New(Options{RateLimit: store})
+}
+
+func New(opts Options) http.Handler {
+	if opts.RateLimit == nil {
+		opts.RateLimit = ratelimit.NewStore()
+	}
+	if opts.Sequence == nil {
+		opts.Sequence = sequence.NewStore()
+	}
+	if opts.Chaos == nil {
+		opts.Chaos = chaos.NewStore()
+	}
+	if opts.Schedule == nil {
+		opts.Schedule = schedule.NewStore()
+	}
+	if opts.Capacity == nil {
+		opts.Capacity = capacity.NewStore()
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		sc, err := scenario.ParseRequest(r)
+		sc, err := resolve(opts.Resolvers, r)
+		if errors.Is(err, scenario.ErrUnsupportedProtocol) {
+			http.NotFound(w, r)
+			return
+		}
 		if err != nil {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
 		clientIP := ip.ClientIP(r)
-		if !ratelimit.Allow(store, sc, clientIP) {
-			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		now := time.Now()
+		limitKey := ratelimit.LimitKey(sc, r, clientIP)
+		decision, limited := ratelimit.Check(opts.RateLimit, sc, limitKey, now)
+		if limited {
+			ratelimit.SetHeaders(w.Header(), *sc.RateLimit, decision, now)
+		}
+		if !decision.Allowed {
+			if delay.Sleep(r.Context(), sc.RateLimit.Reject.Delay) {
+				protocol.WriteRateLimited(w, r, sc)
+			}
+			return
+		}
+		release, ok := ratelimit.Acquire(opts.RateLimit, sc, limitKey)
+		if !ok {
+			protocol.WriteTooManyConcurrent(w, r, sc)
+			return
+		}
+		defer release()
+
+		// A worker is held for the rest of the request, delay included.
+		busy := 0
+		if sc.Capacity != nil {
+			release, n, ok := capacity.Acquire(opts.Capacity, r.Context(), sc)
+			if !ok {
+				overloaded(w, r, sc)
+				return
+			}
+			defer release()
+			busy = n
+		}
+
+		reqBody, ok := consumeBody(w, r, sc)
+		if !ok {
 			return
 		}
 
+		// Scheduled outages do not consume sequence steps.
+		down := schedule.Down(opts.Schedule, sc, now)
+		if !down {
+			if step, ok := sequence.Next(opts.Sequence, sc, clientIP); ok {
+				sc.StatusCode = step.StatusCode
+				if step.Body != "" {
+					sc.Body = step.Body
+				}
+			}
+		}
+
+		if sc.Template {
+			data := tmpl.NewData(r, reqBody, clientIP, sequence.Count(opts.Sequence, sc, clientIP))
+			data.Params = sc.PathParams
+			if sc.Body, err = tmpl.Render(sc.Body, data); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			if sc.Headers, err = tmpl.RenderHeaders(sc.Headers, data); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+		}
+
+		stream := chaos.StreamFor(opts.Chaos, sc, clientIP)
+		// Injected failures answer with a bare status, without the
+		// scenario's body, generated payload or streaming.
+		failStatus, failed := 0, false
+		if down {
+			failStatus, failed = sc.Schedule.Status, true
+		} else {
+			failStatus, failed = chaos.Fail(sc, stream)
+		}
+		if failed {
+			sc.StatusCode = failStatus
+			sc.Body = ""
+			sc.Generator = nil
+			sc.Stream = nil
+		}
+
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sc.Fault != "" {
+				fault.Inject(w, r, sc)
+				return
+			}
+			if sc.BandwidthDown > 0 {
+				w = throttle.ResponseWriter(r.Context(), w, sc.BandwidthDown)
+			}
+			if sc.Redirect != nil && sc.StatusCode >= 300 && sc.StatusCode < 400 {
+				protocol.WriteRedirect(w, r, sc)
+				return
+			}
+
 			switch sc.Protocol {
 			case scenario.ProtocolHTTP:
-				protocol.WriteHTTP(w, sc)
+				protocol.WriteHTTP(w, r, sc)
 			case scenario.ProtocolREST:
-				protocol.WriteREST(w, sc)
+				protocol.WriteREST(w, r, sc)
 			case scenario.ProtocolJSONRPC:
 				protocol.HandleJSONRPC(w, r, sc)
 			default:
@@ -41,6 +173,66 @@ func NewRouter(store *ratelimit.Store) http.Handler {
 			}
 		})
 
-		delay.Wrap(handler, sc.Delay).ServeHTTP(w, r)
+		d := sc.Delay
+		if sc.DelayDist != nil {
+			d = delay.Sample(*sc.DelayDist, chaos.DelayStreamFor(opts.Chaos, sc, clientIP))
+		}
+		d = capacity.Stretch(sc, d, busy)
+		delay.Wrap(handler, d).ServeHTTP(w, r)
 	})
 }
+
+// overloaded rejects a request the simulated backend has no room for.
+func overloaded(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) {
+	if r.Context().Err() != nil {
+		return
+	}
+	if sc.Capacity.Shed {
+		sc.Fault = scenario.FaultClose
+		fault.Inject(w, r, sc)
+		return
+	}
+	http.Error(w, "overloaded", sc.Capacity.Status)
+}
+
+func resolve(resolvers []Resolver, r *http.Request) (scenario.Scenario, error) {
+	for _, resolver := range resolvers {
+		sc, ok, err := resolver.Resolve(r)
+		if ok || err != nil {
+			return sc, err
+		}
+	}
+	return scenario.ParseRequest(r)
+}
+
+// maxBufferedBody caps request bodies buffered for templates and JSON-RPC.
+const maxBufferedBody = 1 << 20
+
+// consumeBody reads the request body up front when the scenario throttles
+// uploads or templates need it. Bodies needed downstream are buffered, up
+// to maxBufferedBody, and replayed; others are discarded at the throttled
+// pace.
+func consumeBody(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) ([]byte, bool) {
+	if sc.BandwidthUp <= 0 && !sc.Template {
+		return nil, true
+	}
+	if sc.BandwidthUp > 0 {
+		r.Body = throttle.ReadCloser(r.Context(), r.Body, sc.BandwidthUp)
+	}
+
+	if !sc.Template && sc.Protocol != scenario.ProtocolJSONRPC {
+		_, err := io.Copy(io.Discard, r.Body)
+		return nil, err == nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBufferedBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		}
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
@@ -1,14 +1,19 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"rudeserver/internal/ratelimit"
+	"rudeserver/internal/scenario"
 )
 
 func TestRouterHTTP(t *testing.T) {
@@ -145,3 +150,689 @@ func TestRouterDelay(t *testing.T) {
 		t.Fatalf("elapsed = %v", elapsed)
 	}
 }
+
+func TestRouterSequence(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+
+	want := []int{503, 503, 200, 200}
+	for i, code := range want {
+		req := httptest.NewRequest(http.MethodGet, "/http/retry?seq=503x2,200:ok", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != code {
+			t.Fatalf("call %d: status = %d, want %d", i, rec.Code, code)
+		}
+		if code == 200 && rec.Body.String() != "ok" {
+			t.Fatalf("call %d: body = %q", i, rec.Body.String())
+		}
+	}
+}
+
+func TestRouterSeededFailuresAreReproducible(t *testing.T) {
+	run := func() []int {
+		router := NewRouter(ratelimit.NewStore())
+		codes := make([]int, 0, 20)
+		for i := 0; i < 20; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/http/flaky?fail=0.5&fail_status=500:2,503:1&seed=7", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			codes = append(codes, rec.Code)
+		}
+		return codes
+	}
+
+	first, second := run(), run()
+	failures := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("call %d: %d != %d", i, first[i], second[i])
+		}
+		if first[i] != http.StatusOK {
+			failures++
+		}
+	}
+	if failures == 0 || failures == len(first) {
+		t.Fatalf("failures = %d of %d", failures, len(first))
+	}
+}
+
+func TestRouterDelayRange(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200?delay=30ms..60ms", nil)
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(rec, req)
+	elapsed := time.Since(start)
+
+	if elapsed < 30*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+}
+
+func TestRouterDelayStopsWhenClientLeaves(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200?delay=5s&body=late", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(rec, req)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("body = %q", rec.Body.String())
+	}
+}
+
+func TestRouterFaultClose(t *testing.T) {
+	srv := httptest.NewServer(NewRouter(ratelimit.NewStore()))
+	defer srv.Close()
+
+	if _, err := http.Get(srv.URL + "/http/status/200?fault=close"); err == nil {
+		t.Fatal("expected transport error")
+	}
+}
+
+func TestRouterStreamsChunks(t *testing.T) {
+	srv := httptest.NewServer(NewRouter(ratelimit.NewStore()))
+	defer srv.Close()
+
+	start := time.Now()
+	res, err := http.Get(srv.URL + "/http/status/200?body=abcdef&chunks=3&chunk_delay=30ms")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer res.Body.Close()
+
+	first := make([]byte, 16)
+	n, err := res.Body.Read(first)
+	if err != nil {
+		t.Fatalf("read first chunk: %v", err)
+	}
+	if string(first[:n]) != "ab" {
+		t.Fatalf("first chunk = %q", first[:n])
+	}
+	if elapsed := time.Since(start); elapsed >= 60*time.Millisecond {
+		t.Fatalf("first chunk arrived after %v", elapsed)
+	}
+
+	rest, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("read rest: %v", err)
+	}
+	if string(rest) != "cdef" {
+		t.Fatalf("rest = %q", rest)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+	if len(res.TransferEncoding) == 0 || res.TransferEncoding[0] != "chunked" {
+		t.Fatalf("transfer-encoding = %v", res.TransferEncoding)
+	}
+}
+
+func TestRouterBandwidthDown(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	body := strings.Repeat("x", 300)
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200?bw_down=1000&body="+body, nil)
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(rec, req)
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+	if rec.Body.String() != body {
+		t.Fatalf("body len = %d", rec.Body.Len())
+	}
+}
+
+func TestRouterBandwidthUp(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	req := httptest.NewRequest(http.MethodPost, "/http/status/200?bw_up=1000", strings.NewReader(strings.Repeat("y", 300)))
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(rec, req)
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
+
+func TestRouterBandwidthUpJSONRPC(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	body := `{"jsonrpc":"2.0","id":1,"params":"` + strings.Repeat("z", 200) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/jsonrpc/status/200?bw_up=1000", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouterGeneratedBody(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200?size=1MB&body=ab", nil)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Body.Len() != 1<<20 {
+		t.Fatalf("body len = %d", rec.Body.Len())
+	}
+	if rec.Header().Get("Content-Length") != "1048576" {
+		t.Fatalf("content-length = %q", rec.Header().Get("Content-Length"))
+	}
+	if !strings.HasPrefix(rec.Body.String(), "abab") {
+		t.Fatalf("body prefix = %q", rec.Body.String()[:8])
+	}
+}
+
+func TestRouterGeneratedJSON(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	req := httptest.NewRequest(http.MethodGet, "/rest/status/200?json_size=64KB", nil)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if !json.Valid(rec.Body.Bytes()) {
+		t.Fatal("expected valid json")
+	}
+	if rec.Body.Len() < 60*1024 || rec.Body.Len() > 64*1024 {
+		t.Fatalf("body len = %d", rec.Body.Len())
+	}
+}
+
+func TestRouterEndlessBody(t *testing.T) {
+	srv := httptest.NewServer(NewRouter(ratelimit.NewStore()))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/http/status/200?size=inf")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	n, err := io.CopyN(io.Discard, res.Body, 4<<20)
+	if err != nil || n != 4<<20 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	res.Body.Close()
+}
+
+func TestRouterTemplateEchoesRequest(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	q := url.Values{
+		"tmpl": {"1"},
+		"body": {`{"id":"{{.JSON.id}}","call":{{.Count}}}`},
+		"h":    {`X-Request-Id:{{.Headers.Get "X-Request-Id"}}`},
+	}
+
+	for call := 1; call <= 2; call++ {
+		req := httptest.NewRequest(http.MethodPost, "/http/orders?"+q.Encode(), strings.NewReader(`{"id":"o-7"}`))
+		req.Header.Set("X-Request-Id", "r-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		want := `{"id":"o-7","call":` + strconv.Itoa(call) + `}`
+		if rec.Body.String() != want {
+			t.Fatalf("body = %q, want %q", rec.Body.String(), want)
+		}
+		if rec.Header().Get("X-Request-Id") != "r-1" {
+			t.Fatalf("header = %q", rec.Header().Get("X-Request-Id"))
+		}
+	}
+}
+
+func TestRouterTemplateJSONRPCKeepsBody(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	q := url.Values{"tmpl": {"1"}, "body": {`{"echo":"{{.JSON.method}}"}`}}
+	body := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+	req := httptest.NewRequest(http.MethodPost, "/jsonrpc/status/200?"+q.Encode(), strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"echo":"ping"`) {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouterTemplateInvalid(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200?tmpl=1&body="+url.QueryEscape("{{.Nope"), nil)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
+
+func TestRouterRedirectChain(t *testing.T) {
+	srv := httptest.NewServer(NewRouter(ratelimit.NewStore()))
+	defer srv.Close()
+
+	var hops []int
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		hops = append(hops, req.Response.StatusCode)
+		return nil
+	}}
+	res, err := client.Get(srv.URL + "/http/redirect/3?code=307,308&to=/http/status/204")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("final status = %d", res.StatusCode)
+	}
+	want := []int{307, 308, 307}
+	if len(hops) != len(want) {
+		t.Fatalf("hops = %v", hops)
+	}
+	for i := range want {
+		if hops[i] != want[i] {
+			t.Fatalf("hops = %v, want %v", hops, want)
+		}
+	}
+}
+
+func TestRouterRedirectLocation(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.test/rest/redirect/2?scheme=https", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("location: %v", err)
+	}
+	if loc.Scheme != "https" || loc.Host != "example.test" || loc.Path != "/rest/redirect/1" || loc.Query().Get("start") != "2" {
+		t.Fatalf("location = %q", loc)
+	}
+}
+
+func TestRouterRedirectLoop(t *testing.T) {
+	router := NewRouter(ratelimit.NewStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/http/redirect/1?loop=1&start=2", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	if loc.Path != "/http/redirect/2" {
+		t.Fatalf("location = %q", loc)
+	}
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	if _, err := http.Get(srv.URL + "/http/redirect/3?loop=1"); err == nil || !strings.Contains(err.Error(), "stopped after 10 redirects") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+type staticResolver struct {
+	path string
+	sc   scenario.Scenario
+}
+
+func (s staticResolver) Resolve(r *http.Request) (scenario.Scenario, bool, error) {
+	if r.URL.Path != s.path {
+		return scenario.Scenario{}, false, nil
+	}
+	return s.sc, true, nil
+}
+
+func TestRouterResolversRunFirst(t *testing.T) {
+	sc, err := scenario.FromSpec(scenario.Spec{Status: 202, Body: "order {{.Params.id}}", Params: map[string]string{"tmpl": "1"}}, http.MethodGet, "/v1/orders/7")
+	if err != nil {
+		t.Fatalf("from spec: %v", err)
+	}
+	sc.PathParams = map[string]string{"id": "7"}
+	router := New(Options{Resolvers: []Resolver{staticResolver{path: "/v1/orders/7", sc: sc}}})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/orders/7", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != 202 || rec.Body.String() != "order 7" {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/http/status/418", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != 418 {
+		t.Fatalf("fallback status = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown path status = %d", rec.Code)
+	}
+}
+
+func TestRouterScheduledOutage(t *testing.T) {
+	router := New(Options{})
+	target := "/http/status/200?outage=..200ms&sched_status=502&seq=201,202&body=up"
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadGateway || rec.Body.Len() != 0 {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != 201 {
+		t.Fatalf("after outage status = %d, want first sequence step", rec.Code)
+	}
+}
+
+func TestRouterCapacityOverload(t *testing.T) {
+	srv := httptest.NewServer(New(Options{}))
+	defer srv.Close()
+	target := srv.URL + "/http/status/200?workers=1&queue=1&delay=300ms"
+
+	codes := make(chan int, 3)
+	for range 3 {
+		go func() {
+			resp, err := http.Get(target)
+			if err != nil {
+				codes <- 0
+				return
+			}
+			resp.Body.Close()
+			codes <- resp.StatusCode
+		}()
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	counts := map[int]int{}
+	for range 3 {
+		counts[<-codes]++
+	}
+	if counts[200] != 2 || counts[503] != 1 {
+		t.Fatalf("status counts = %v", counts)
+	}
+
+	shed := srv.URL + "/http/status/200?workers=1&overload=shed&delay=300ms"
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(shed)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if resp, err := http.Get(shed); err == nil {
+		resp.Body.Close()
+		t.Fatalf("shed request got status %d", resp.StatusCode)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+}
+
+func TestRouterConcurrencyLimit(t *testing.T) {
+	router := New(Options{})
+	target := "/http/status/200?conc=1&conc_status=503&delay=200ms"
+
+	done := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		done <- rec.Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("concurrent status = %d", rec.Code)
+	}
+	if code := <-done; code != 200 {
+		t.Fatalf("first status = %d", code)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != 200 {
+		t.Fatalf("after release status = %d", rec.Code)
+	}
+}
+
+func TestRouterConcurrencyRejectionUsesProtocolShape(t *testing.T) {
+	router := New(Options{})
+	target := "/rest/status/200?conc=1&delay=200ms"
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	<-done
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("status = %d, headers = %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(rec.Body.String(), `"detail":"too many concurrent requests"`) {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+}
+
+func TestRouterRateLimitHeaders(t *testing.T) {
+	router := New(Options{})
+	target := "/http/status/200?rl=1&rl_algo=fixed&rl_window=1h"
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != 200 || rec.Header().Get("X-RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") != "" {
+		t.Fatalf("allowed: status = %d, headers = %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" || rec.Header().Get("RateLimit-Policy") == "" {
+		t.Fatalf("rejected: status = %d, headers = %v", rec.Code, rec.Header())
+	}
+}
+
+func TestRouterRateLimitRejection(t *testing.T) {
+	router := New(Options{})
+
+	target := "/http/status/200?rl=1&rl_algo=fixed&rl_window=1h&rl_status=503&rl_body=busy&rl_h=X-Throttled:1&rl_delay=50ms"
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != 503 || rec.Body.String() != "busy\n" || rec.Header().Get("X-Throttled") != "1" {
+		t.Fatalf("http: status = %d, body = %q, headers = %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("http: rejection was not delayed")
+	}
+
+	target = "/rest/status/200?rl=1&rl_algo=fixed&rl_window=1h"
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var problem map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem["status"] != 429.0 {
+		t.Fatalf("rest: body = %q, err = %v", rec.Body.String(), err)
+	}
+	if rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("rest: content type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	target = "/jsonrpc/status/200?rl=1&rl_algo=fixed&rl_window=1h&rl_status=200"
+	call := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"ping"}`)))
+		return rec
+	}
+	call()
+	rec = call()
+	var response struct {
+		ID    any `json:"id"`
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || rec.Code != 200 {
+		t.Fatalf("jsonrpc: status = %d, body = %q, err = %v", rec.Code, rec.Body.String(), err)
+	}
+	if response.ID != 7.0 || response.Error.Code != -32005 || response.Error.Message != "rate limited" {
+		t.Fatalf("jsonrpc: response = %+v", response)
+	}
+}
+
+func TestRouterRateLimitKeys(t *testing.T) {
+	router := New(Options{})
+	get := func(target string, apiKey string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if apiKey != "" {
+			req.Header.Set("X-Api-Key", apiKey)
+		}
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Two endpoints draw from one bucket.
+	quota := "?rl=1&rl_algo=fixed&rl_window=1h&rl_key=bucket:shared"
+	if code := get("/http/status/200"+quota, ""); code != 200 {
+		t.Fatalf("bucket first = %d", code)
+	}
+	if code := get("/http/status/201"+quota, ""); code != http.StatusTooManyRequests {
+		t.Fatalf("bucket second = %d", code)
+	}
+
+	// Each tenant token gets its own quota on the same route.
+	quota = "?rl=1&rl_algo=fixed&rl_window=1h&rl_key=header:X-Api-Key"
+	if get("/http/status/202"+quota, "a") != 202 || get("/http/status/202"+quota, "b") != 202 {
+		t.Fatal("tenants should not share a quota")
+	}
+	if code := get("/http/status/202"+quota, "a"); code != http.StatusTooManyRequests {
+		t.Fatalf("tenant a again = %d", code)
+	}
+}
+
+// chunkRecorder records each write as a separate chunk.
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	chunks []string
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	c.chunks = append(c.chunks, string(p))
+	return c.ResponseRecorder.Write(p)
+}
+
+func TestRouterStreamsExactChunkCount(t *testing.T) {
+	rec := &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+	NewRouter(ratelimit.NewStore()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/http/status/200?body=abcdef&chunks=4", nil))
+	if got := strings.Join(rec.chunks, ","); got != "ab,cd,e,f" {
+		t.Fatalf("chunks = %q", got)
+	}
+}
+
+func TestRouterStreamsHugeChunk(t *testing.T) {
+	srv := httptest.NewServer(NewRouter(ratelimit.NewStore()))
+	defer srv.Close()
+
+	// A single 50GB chunk must be copied through, not buffered.
+	res, err := http.Get(srv.URL + "/http/status/200?size=50GB&chunks=1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	n, err := io.CopyN(io.Discard, res.Body, 1<<20)
+	res.Body.Close()
+	if err != nil || n != 1<<20 {
+		t.Fatalf("read %d bytes, err = %v", n, err)
+	}
+}
+
+func TestRouterFailureDropsGeneratedBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New(Options{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/http/status/200?fail=1&fail_status=503&size=10MB&chunks=4", nil))
+	if rec.Code != 503 || rec.Body.Len() != 0 {
+		t.Fatalf("status = %d, body = %d bytes", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestRouterTemplateBodyTooLarge(t *testing.T) {
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(strings.Repeat("x", maxBufferedBody+1))
+	New(Options{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/http/status/200?tmpl=1&body={{.Body}}", body))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
+
+func TestRouterBucketKeepsFirstQuota(t *testing.T) {
+	router := New(Options{})
+	get := func(target string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code
+	}
+
+	// Routes naming different quotas still draw from one bucket, which
+	// keeps the quota it was created with.
+	small := "/http/status/200?rl=2&rl_algo=fixed&rl_window=1h&rl_key=bucket:mixed"
+	large := "/http/status/201?rl=5&rl_algo=fixed&rl_window=1h&rl_key=bucket:mixed"
+	if get(small) != 200 || get(large) != 201 {
+		t.Fatal("bucket should allow two requests")
+	}
+	if code := get(small); code != http.StatusTooManyRequests {
+		t.Fatalf("small third = %d", code)
+	}
+	if code := get(large); code != http.StatusTooManyRequests {
+		t.Fatalf("large third = %d", code)
+	}
+}
+
+func TestRouterGlobalKeyKeepsFirstQuota(t *testing.T) {
+	router := New(Options{})
+	allowed := 0
+	for i := range 10 {
+		target := "/http/a?rl=1&burst=1&rl_key=global"
+		if i%2 == 1 {
+			target = "/http/b?rl=2&burst=1&rl_key=global"
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code == 200 {
+			allowed++
+		}
+	}
+	if allowed > 2 {
+		t.Fatalf("allowed %d of 10 on a mixed-quota global key", allowed)
+	}
+}
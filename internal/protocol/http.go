@@ -2,19 +2,36 @@ package protocol
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
+	"rudeserver/internal/bodygen"
 	"rudeserver/internal/scenario"
 )
 
-func WriteHTTP(w http.ResponseWriter, sc scenario.Scenario) {
+func WriteHTTP(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) {
 	writeHeaders(w, sc.Headers)
 	status := sc.StatusCode
 	if status == 0 {
 		status = http.StatusOK
 	}
+
+	if sc.Generator != nil {
+		size := sc.Generator.Size
+		if size >= 0 && sc.Generator.Kind == scenario.GenBytes && sc.Stream == nil && w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		if sc.Generator.Kind == scenario.GenJSON && w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		}
+		w.WriteHeader(status)
+		writeBody(w, r, sc, bodygen.Reader(*sc.Generator, sc.Body, sc.Seed), size)
+		return
+	}
+
 	w.WriteHeader(status)
 	if sc.Body != "" {
-		_, _ = w.Write([]byte(sc.Body))
+		writeBody(w, r, sc, strings.NewReader(sc.Body), int64(len(sc.Body)))
 	}
 }
 
@@ -25,4 +42,3 @@ func writeHeaders(w http.ResponseWriter, headers http.Header) {
 		}
 	}
 }
-
@@ -1,11 +1,13 @@
 package protocol
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"net/http"
 	"strings"
 
+	"rudeserver/internal/bodygen"
 	"rudeserver/internal/scenario"
 )
 
@@ -39,7 +41,17 @@ func HandleJSONRPC(w http.ResponseWriter, r *http.Request, sc scenario.Scenario)
 		return
 	}
 
-	result := resolveResult(sc.Body, request)
+	body := sc.Body
+	if sc.Generator != nil {
+		generated, err := io.ReadAll(bodygen.Reader(*sc.Generator, sc.Body, sc.Seed))
+		if err != nil {
+			http.Error(w, "generate body failed", http.StatusInternalServerError)
+			return
+		}
+		body = string(generated)
+	}
+
+	result := resolveResult(body, request)
 
 	response := map[string]any{
 		"jsonrpc": "2.0",
@@ -56,8 +68,10 @@ func HandleJSONRPC(w http.ResponseWriter, r *http.Request, sc scenario.Scenario)
 	if status == 0 {
 		status = http.StatusOK
 	}
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(response)
 	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(response)
+	writeBody(w, r, sc, &buf, int64(buf.Len()))
 }
 
 func resolveResult(body string, request map[string]any) any {
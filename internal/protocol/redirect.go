@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rudeserver/internal/scenario"
+)
+
+// WriteRedirect answers one hop of a redirect chain. Intermediate hops point
+// at the next /redirect/{n-1} URL with the same query; the last hop points
+// at the target, or back at the first hop when looping.
+func WriteRedirect(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) {
+	rd := sc.Redirect
+
+	var location string
+	switch {
+	case rd.Hops > 1:
+		location = hopURL(r, sc, rd.Hops-1)
+	case rd.Loop:
+		location = hopURL(r, sc, rd.Start)
+	default:
+		location = rd.To
+	}
+
+	if rd.Absolute && strings.HasPrefix(location, "/") {
+		scheme := rd.Scheme
+		if scheme == "" {
+			scheme = "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+		}
+		location = scheme + "://" + r.Host + location
+	}
+
+	writeHeaders(w, sc.Headers)
+	w.Header().Set("Location", location)
+	w.WriteHeader(sc.StatusCode)
+	if sc.Body != "" {
+		_, _ = w.Write([]byte(sc.Body))
+	}
+}
+
+func hopURL(r *http.Request, sc scenario.Scenario, hops int) string {
+	q := make(url.Values, len(r.URL.Query())+1)
+	for k, v := range r.URL.Query() {
+		q[k] = v
+	}
+	q.Set("start", strconv.Itoa(sc.Redirect.Start))
+	return "/" + string(sc.Protocol) + "/redirect/" + strconv.Itoa(hops) + "?" + q.Encode()
+}
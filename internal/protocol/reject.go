@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"rudeserver/internal/scenario"
+)
+
+// jsonrpcLimitExceeded is the JSON-RPC server error code commonly used for
+// rate limiting and other refused-for-load errors.
+const jsonrpcLimitExceeded = -32005
+
+// WriteRateLimited rejects a rate-limited request in the shape its clients
+// expect: a JSON-RPC error object for jsonrpc, problem+json for rest and
+// plain text otherwise. A rl_body that is a JSON object replaces the
+// error object or problem document; any other rl_body becomes its message.
+func WriteRateLimited(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) {
+	reject := sc.RateLimit.Reject
+	message := reject.Body
+	if message == "" {
+		message = "rate limited"
+	}
+	writeRejection(w, r, sc, reject.Status, message, reject.Body, reject.Headers)
+}
+
+// WriteTooManyConcurrent rejects a request over the scenario's conc limit
+// in the same shapes as WriteRateLimited.
+func WriteTooManyConcurrent(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) {
+	writeRejection(w, r, sc, sc.Concurrency.Status, "too many concurrent requests", "", nil)
+}
+
+// writeRejection writes status with message in the protocol's error shape.
+// A raw body that is a JSON object replaces the error object or problem
+// document.
+func writeRejection(w http.ResponseWriter, r *http.Request, sc scenario.Scenario, status int, message string, raw string, headers http.Header) {
+	var body []byte
+	contentType := "text/plain; charset=utf-8"
+	switch sc.Protocol {
+	case scenario.ProtocolJSONRPC:
+		var detail any = map[string]any{"code": jsonrpcLimitExceeded, "message": message}
+		if object, ok := jsonObject(raw); ok {
+			detail = object
+		}
+		body, _ = json.Marshal(map[string]any{"jsonrpc": "2.0", "id": requestID(r), "error": detail})
+		contentType = "application/json; charset=utf-8"
+	case scenario.ProtocolREST:
+		var problem any = map[string]any{
+			"type":   "about:blank",
+			"title":  http.StatusText(status),
+			"status": status,
+			"detail": message,
+		}
+		if object, ok := jsonObject(raw); ok {
+			problem = object
+		}
+		body, _ = json.Marshal(problem)
+		contentType = "application/problem+json"
+	default:
+		body = []byte(message)
+	}
+
+	writeHeaders(w, headers)
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+func jsonObject(raw string) (map[string]any, bool) {
+	trimmed := strings.TrimSpace(raw)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var object map[string]any
+	if err := json.Unmarshal([]byte(trimmed), &object); err != nil {
+		return nil, false
+	}
+	return object, true
+}
+
+// requestID echoes the JSON-RPC request id, or null when the body has
+// none or cannot be read.
+func requestID(r *http.Request) any {
+	if r.Body == nil {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil
+	}
+	var request struct {
+		ID any `json:"id"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&request); err != nil {
+		return nil
+	}
+	return request.ID
+}
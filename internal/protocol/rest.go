@@ -6,6 +6,6 @@ import (
 	"rudeserver/internal/scenario"
 )
 
-func WriteREST(w http.ResponseWriter, sc scenario.Scenario) {
-	WriteHTTP(w, sc)
+func WriteREST(w http.ResponseWriter, r *http.Request, sc scenario.Scenario) {
+	WriteHTTP(w, r, sc)
 }
@@ -0,0 +1,66 @@
+package protocol
+
+import (
+	"io"
+	"net/http"
+
+	"rudeserver/internal/delay"
+	"rudeserver/internal/scenario"
+)
+
+// endlessChunkSize is the streamed chunk size for bodies without a known length.
+const endlessChunkSize = 32 * 1024
+
+// writeBody copies size bytes of body (or until EOF when size is negative)
+// in one go, or as sc.Stream.Chunks flushed chunks separated by
+// sc.Stream.Delay. Writing stops early if the client leaves.
+func writeBody(w http.ResponseWriter, r *http.Request, sc scenario.Scenario, body io.Reader, size int64) {
+	if sc.Stream == nil {
+		_, _ = io.Copy(w, body)
+		return
+	}
+	if size == 0 {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	buf := make([]byte, endlessChunkSize)
+	if size < 0 {
+		for i := 0; ; i++ {
+			n, err := io.ReadFull(body, buf)
+			if n > 0 {
+				if i > 0 && !delay.Sleep(r.Context(), sc.Stream.Delay) {
+					return
+				}
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return
+				}
+				_ = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	// Known sizes are split into exactly Chunks chunks, the first
+	// size%chunks of them one byte longer. Chunks are copied through buf,
+	// so their size does not decide how much is held in memory.
+	chunks := min(int64(sc.Stream.Chunks), size)
+	for i := int64(0); i < chunks; i++ {
+		n := size / chunks
+		if i < size%chunks {
+			n++
+		}
+		if i > 0 && !delay.Sleep(r.Context(), sc.Stream.Delay) {
+			return
+		}
+		written, err := io.CopyBuffer(w, io.LimitReader(body, n), buf)
+		if written > 0 {
+			_ = rc.Flush()
+		}
+		if err != nil || written < n {
+			return
+		}
+	}
+}
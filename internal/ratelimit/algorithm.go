@@ -0,0 +1,190 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"rudeserver/internal/scenario"
+)
+
+// limiter is one rate-limiting algorithm's state for a single key.
+// decide reports the state at now; with take it also counts a request,
+// which is only allowed when there is room for it.
+type limiter interface {
+	decide(now time.Time, take bool) Decision
+}
+
+func newLimiter(config scenario.RateLimit) limiter {
+	switch config.Algorithm {
+	case scenario.RateFixed:
+		return &fixedWindow{limit: config.Limit, window: config.Window}
+	case scenario.RateSliding:
+		return &slidingWindow{limit: config.Limit, window: config.Window}
+	case scenario.RateLog:
+		return &slidingLog{limit: config.Limit, window: config.Window}
+	default:
+		return &tokenBucket{
+			Limiter: rate.NewLimiter(rate.Limit(config.RPS), config.Burst),
+			rps:     config.RPS,
+			burst:   config.Burst,
+		}
+	}
+}
+
+// tokenBucket reports its burst as the limit, and the time to refill the
+// whole burst as both its window and its reset.
+type tokenBucket struct {
+	*rate.Limiter
+	rps   float64
+	burst int
+}
+
+func (b *tokenBucket) decide(now time.Time, take bool) Decision {
+	allowed := b.TokensAt(now) >= 1
+	if take {
+		allowed = b.AllowN(now, 1)
+	}
+	tokens := b.TokensAt(now)
+
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     b.burst,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Window:    seconds(float64(b.burst) / b.rps),
+		Reset:     seconds((float64(b.burst) - tokens) / b.rps),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - tokens) / b.rps)
+	}
+	return d
+}
+
+// fixedWindow counts requests in windows aligned to the clock, so quotas
+// reset hard at every window boundary.
+type fixedWindow struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+func (f *fixedWindow) decide(now time.Time, take bool) Decision {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if start := now.Truncate(f.window); !start.Equal(f.start) {
+		f.start, f.count = start, 0
+	}
+	allowed := f.count < f.limit
+	if allowed && take {
+		f.count++
+	}
+
+	reset := f.start.Add(f.window).Sub(now)
+	d := Decision{Allowed: allowed, Limit: f.limit, Remaining: f.limit - f.count, Window: f.window, Reset: reset}
+	if !allowed {
+		d.RetryAfter = reset
+	}
+	return d
+}
+
+// slidingWindow approximates a rolling window from the current and the
+// previous fixed window, weighting the previous count by how much of it
+// still overlaps the rolling window.
+type slidingWindow struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	start    time.Time
+	count    int
+	previous int
+}
+
+func (s *slidingWindow) decide(now time.Time, take bool) Decision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := now.Truncate(s.window)
+	switch {
+	case start.Equal(s.start):
+	case start.Sub(s.start) == s.window:
+		s.start, s.previous, s.count = start, s.count, 0
+	default:
+		s.start, s.previous, s.count = start, 0, 0
+	}
+
+	elapsed := now.Sub(start)
+	overlap := 1 - float64(elapsed)/float64(s.window)
+	estimate := float64(s.previous)*overlap + float64(s.count)
+	allowed := estimate < float64(s.limit)
+	if allowed && take {
+		s.count++
+		estimate++
+	}
+
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     s.limit,
+		Remaining: max(int(math.Floor(float64(s.limit)-estimate)), 0),
+		Window:    s.window,
+		Reset:     s.window - elapsed,
+	}
+	if !allowed {
+		d.RetryAfter = s.retryAfter(elapsed)
+	}
+	return d
+}
+
+// retryAfter finds when the estimate next drops below the limit: later in
+// this window as the previous one fades out, or in the next window as
+// this one starts to.
+func (s *slidingWindow) retryAfter(elapsed time.Duration) time.Duration {
+	w := float64(s.window)
+	if s.count < s.limit && s.previous > 0 {
+		at := time.Duration(w * (1 - float64(s.limit-s.count)/float64(s.previous)))
+		return max(at-elapsed, 0)
+	}
+	next := s.window - elapsed
+	return next + time.Duration(w*(1-float64(s.limit)/float64(s.count)))
+}
+
+// slidingLog keeps the time of every allowed request in the rolling window.
+type slidingLog struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	times  []time.Time
+}
+
+func (l *slidingLog) decide(now time.Time, take bool) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(l.times) && !l.times[i].After(cutoff) {
+		i++
+	}
+	l.times = l.times[i:]
+
+	allowed := len(l.times) < l.limit
+	if allowed && take {
+		l.times = append(l.times, now)
+	}
+
+	d := Decision{Allowed: allowed, Limit: l.limit, Remaining: l.limit - len(l.times), Window: l.window}
+	if len(l.times) > 0 {
+		d.Reset = l.times[len(l.times)-1].Add(l.window).Sub(now)
+	}
+	if !allowed {
+		d.RetryAfter = l.times[0].Add(l.window).Sub(now)
+	}
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+var epoch = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func allowAt(t *testing.T, l limiter, offsets []time.Duration) []bool {
+	t.Helper()
+	out := make([]bool, len(offsets))
+	for i, offset := range offsets {
+		out[i] = l.decide(epoch.Add(offset), true).Allowed
+	}
+	return out
+}
+
+func expect(t *testing.T, name string, got []bool, want []bool) {
+	t.Helper()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: request %d allowed = %v, want %v (all: %v)", name, i, got[i], want[i], got)
+		}
+	}
+}
+
+func TestFixedWindowResetsAtBoundary(t *testing.T) {
+	l := newLimiter(scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 2, Window: time.Minute})
+	got := allowAt(t, l, []time.Duration{
+		50 * time.Second, 55 * time.Second, 59 * time.Second,
+		60 * time.Second, 61 * time.Second, 62 * time.Second,
+	})
+	expect(t, "fixed", got, []bool{true, true, false, true, true, false})
+}
+
+func TestSlidingWindowWeighsPreviousWindow(t *testing.T) {
+	l := newLimiter(scenario.RateLimit{Algorithm: scenario.RateSliding, Limit: 4, Window: time.Minute})
+	got := allowAt(t, l, []time.Duration{
+		50 * time.Second, 51 * time.Second, 52 * time.Second, 53 * time.Second,
+		// 12:01:01 sees 4 * 59/60 from the previous window: one more fits.
+		61 * time.Second, 62 * time.Second,
+		// 12:01:45 sees 4 * 15/60 = 1 from it, plus the one above.
+		105 * time.Second, 106 * time.Second, 107 * time.Second, 108 * time.Second,
+	})
+	expect(t, "sliding", got, []bool{true, true, true, true, true, false, true, true, true, false})
+
+	if !l.decide(epoch.Add(10*time.Minute), true).Allowed {
+		t.Fatal("sliding: idle windows should reset")
+	}
+}
+
+func TestSlidingLogIsExact(t *testing.T) {
+	l := newLimiter(scenario.RateLimit{Algorithm: scenario.RateLog, Limit: 2, Window: 10 * time.Second})
+	got := allowAt(t, l, []time.Duration{
+		0, 9 * time.Second, 9500 * time.Millisecond,
+		10 * time.Second, 11 * time.Second, 19 * time.Second,
+	})
+	expect(t, "log", got, []bool{true, true, false, true, false, true})
+}
+
+func TestTokenBucketIsDefault(t *testing.T) {
+	l := newLimiter(scenario.RateLimit{RPS: 1, Burst: 2})
+	got := allowAt(t, l, []time.Duration{0, 0, 0, time.Second})
+	expect(t, "token", got, []bool{true, true, false, true})
+}
+
+func TestStoreReplacesLimiterOnConfigChange(t *testing.T) {
+	sc := scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		Method:         "GET",
+		NormalizedPath: "/status/200",
+		RateLimit:      &scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 1, Window: time.Hour},
+	}
+	store := NewStore()
+	if !Allow(store, sc, "203.0.113.1") || Allow(store, sc, "203.0.113.1") {
+		t.Fatal("fixed window should allow exactly one request")
+	}
+
+	sc.RateLimit = &scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 2, Window: time.Hour}
+	if !Allow(store, sc, "203.0.113.1") {
+		t.Fatal("changed limit should start a fresh limiter")
+	}
+}
+
+func TestDecisionsReportLimiterState(t *testing.T) {
+	tests := []struct {
+		name   string
+		config scenario.RateLimit
+		want   Decision
+	}{
+		{
+			name:   "token",
+			config: scenario.RateLimit{RPS: 1, Burst: 2},
+			want:   Decision{Limit: 2, Remaining: 0, Window: 2 * time.Second, Reset: 2 * time.Second, RetryAfter: time.Second},
+		},
+		{
+			name:   "fixed",
+			config: scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 2, Window: time.Minute},
+			want:   Decision{Limit: 2, Remaining: 0, Window: time.Minute, Reset: 60 * time.Second, RetryAfter: 60 * time.Second},
+		},
+		{
+			name:   "log",
+			config: scenario.RateLimit{Algorithm: scenario.RateLog, Limit: 2, Window: time.Minute},
+			want:   Decision{Limit: 2, Remaining: 0, Window: time.Minute, Reset: 60 * time.Second, RetryAfter: 60 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		l := newLimiter(tt.config)
+		if d := l.decide(epoch, true); !d.Allowed || d.Remaining != 1 {
+			t.Fatalf("%s: first = %+v", tt.name, d)
+		}
+		l.decide(epoch, true)
+		if d := l.decide(epoch, true); d != tt.want {
+			t.Fatalf("%s: rejected = %+v, want %+v", tt.name, d, tt.want)
+		}
+	}
+}
+
+func TestSlidingWindowRetryAfter(t *testing.T) {
+	l := newLimiter(scenario.RateLimit{Algorithm: scenario.RateSliding, Limit: 2, Window: time.Minute})
+	l.decide(epoch, true)
+	l.decide(epoch, true)
+
+	// The previous window's 2 fade out: at 12:01:30 they weigh 1, leaving
+	// room for one more.
+	if d := l.decide(epoch.Add(time.Minute), true); d.Allowed || d.RetryAfter != 0 {
+		t.Fatalf("boundary = %+v", d)
+	}
+	d := l.decide(epoch.Add(time.Minute+time.Second), true)
+	if !d.Allowed {
+		t.Fatalf("after boundary = %+v", d)
+	}
+	d = l.decide(epoch.Add(time.Minute+2*time.Second), true)
+	if d.Allowed || d.RetryAfter != 28*time.Second {
+		t.Fatalf("rejected = %+v", d)
+	}
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+// SetHeaders advertises the limiter state: X-RateLimit-Limit, -Remaining
+// and -Reset (Unix seconds), the IETF RateLimit and RateLimit-Policy
+// fields (delta seconds), and Retry-After on rejections. rl_headers=none
+// sends none of them, not even Retry-After.
+func SetHeaders(h http.Header, rl scenario.RateLimit, d Decision, now time.Time) {
+	if rl.Headers == scenario.RateHeadersNone {
+		return
+	}
+	if !d.Allowed {
+		if rl.RetryDate {
+			h.Set("Retry-After", now.Add(d.RetryAfter).Add(time.Second-1).UTC().Format(http.TimeFormat))
+		} else {
+			h.Set("Retry-After", strconv.FormatInt(max(ceilSeconds(d.RetryAfter), 1), 10))
+		}
+	}
+
+	reset := ceilSeconds(d.Reset)
+	if rl.Headers == scenario.RateHeadersAll || rl.Headers == scenario.RateHeadersX {
+		h.Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(now.Unix()+reset, 10))
+	}
+	if rl.Headers == scenario.RateHeadersAll || rl.Headers == scenario.RateHeadersIETF {
+		h.Set("RateLimit-Policy", `"default";q=`+strconv.Itoa(d.Limit)+";w="+strconv.FormatInt(ceilSeconds(d.Window), 10))
+		h.Set("RateLimit", `"default";r=`+strconv.Itoa(d.Remaining)+";t="+strconv.FormatInt(reset, 10))
+	}
+}
+
+// ceilSeconds rounds up so clients never come back early.
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(max(d, 0).Seconds()))
+}
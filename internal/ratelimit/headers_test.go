@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+func TestSetHeaders(t *testing.T) {
+	rl := scenario.RateLimit{Headers: scenario.RateHeadersAll}
+	d := Decision{Limit: 10, Remaining: 0, Window: time.Minute, Reset: 1500 * time.Millisecond, RetryAfter: 200 * time.Millisecond}
+
+	h := http.Header{}
+	SetHeaders(h, rl, d, epoch)
+	want := map[string]string{
+		"Retry-After":           "1",
+		"X-Ratelimit-Limit":     "10",
+		"X-Ratelimit-Remaining": "0",
+		"X-Ratelimit-Reset":     "1767268802",
+		"Ratelimit-Policy":      `"default";q=10;w=60`,
+		"Ratelimit":             `"default";r=0;t=2`,
+	}
+	for name, value := range want {
+		if got := h.Get(name); got != value {
+			t.Fatalf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	h = http.Header{}
+	SetHeaders(h, scenario.RateLimit{Headers: scenario.RateHeadersIETF, RetryDate: true}, d, epoch)
+	if got := h.Get("Retry-After"); got != "Thu, 01 Jan 2026 12:00:01 GMT" {
+		t.Fatalf("Retry-After = %q", got)
+	}
+	if h.Get("X-RateLimit-Limit") != "" || h.Get("RateLimit") == "" {
+		t.Fatalf("ietf headers = %v", h)
+	}
+
+	d.Allowed = true
+	h = http.Header{}
+	SetHeaders(h, scenario.RateLimit{Headers: scenario.RateHeadersX}, d, epoch)
+	if h.Get("Retry-After") != "" || h.Get("RateLimit") != "" || h.Get("X-RateLimit-Limit") != "10" {
+		t.Fatalf("x headers = %v", h)
+	}
+
+	h = http.Header{}
+	SetHeaders(h, scenario.RateLimit{Headers: scenario.RateHeadersNone}, Decision{}, epoch)
+	if len(h) != 0 {
+		t.Fatalf("none headers = %v", h)
+	}
+}
@@ -1,46 +1,123 @@
 package ratelimit
 
 import (
-	"sync"
+	"net/http"
+	"strings"
+	"time"
 
-	"golang.org/x/time/rate"
 	"rudeserver/internal/scenario"
 )
 
-type Store struct {
-	mu       sync.Mutex
-	limiters map[string]*rate.Limiter
+func Key(sc scenario.Scenario, clientIP string) string {
+	return string(sc.Protocol) + "|" + sc.Method + "|" + sc.NormalizedPath + "|" + clientIP
 }
 
-func NewStore() *Store {
-	return &Store{
-		limiters: make(map[string]*rate.Limiter),
+// The global key and the keys of named buckets span routes, so their
+// limiter keeps the quota of the first request even when other routes
+// name other quotas.
+const (
+	globalKey    = "global"
+	bucketPrefix = "bucket|"
+)
+
+// shared reports whether key spans routes.
+func shared(key string) bool {
+	return key == globalKey || strings.HasPrefix(key, bucketPrefix)
+}
+
+// LimitKey is the key rate and concurrency limits count against, chosen
+// by rl_key. Header keys stay per route; requests without the header
+// share one key. The global key and buckets span every route using them.
+func LimitKey(sc scenario.Scenario, r *http.Request, clientIP string) string {
+	route := string(sc.Protocol) + "|" + sc.Method + "|" + sc.NormalizedPath
+	switch sc.RateKey.Kind {
+	case scenario.RateKeyPath:
+		return route
+	case scenario.RateKeyGlobal:
+		return globalKey
+	case scenario.RateKeyHeader:
+		return route + "|" + sc.RateKey.Name + "=" + r.Header.Get(sc.RateKey.Name)
+	case scenario.RateKeyBucket:
+		return bucketPrefix + sc.RateKey.Name
+	default:
+		return Key(sc, clientIP)
 	}
 }
 
-func Key(sc scenario.Scenario, clientIP string) string {
-	return string(sc.Protocol) + "|" + sc.Method + "|" + sc.NormalizedPath + "|" + clientIP
+// Decision is the outcome of a rate-limit check and the limiter state
+// behind it. RetryAfter is only set for rejected requests.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Window     time.Duration
+	Reset      time.Duration
+	RetryAfter time.Duration
 }
 
 func Allow(store *Store, sc scenario.Scenario, clientIP string) bool {
+	d, _ := Check(store, sc, Key(sc, clientIP), time.Now())
+	return d.Allowed
+}
+
+// Check counts the request against the limiter for key. limited is false
+// when the scenario has no rate limit; the request is then allowed.
+func Check(store *Store, sc scenario.Scenario, key string, now time.Time) (Decision, bool) {
 	if sc.RateLimit == nil {
-		return true
+		return Decision{Allowed: true}, false
 	}
 
-	key := Key(sc, clientIP)
-	limiter := store.getLimiter(key, sc.RateLimit.RPS, sc.RateLimit.Burst)
-	return limiter.Allow()
+	limiter := store.limiter(key, *sc.RateLimit, now)
+	return limiter.decide(now, true), true
+}
+
+// quota is the part of a rate limit that shapes the limiter; headers and
+// the rejection response do not.
+type quota struct {
+	algorithm scenario.RateAlgorithm
+	rps       float64
+	burst     int
+	limit     int
+	window    time.Duration
+}
+
+func quotaOf(rl scenario.RateLimit) quota {
+	return quota{algorithm: rl.Algorithm, rps: rl.RPS, burst: rl.Burst, limit: rl.Limit, window: rl.Window}
 }
 
-func (s *Store) getLimiter(key string, rps float64, burst int) *rate.Limiter {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// recovery is how long a limiter must sit unused before it is as good as
+// new, so dropping it cannot hand a client extra quota.
+func (q quota) recovery() time.Duration {
+	switch q.algorithm {
+	case scenario.RateFixed, scenario.RateLog:
+		return q.window
+	case scenario.RateSliding:
+		return 2 * q.window
+	default:
+		return seconds(float64(q.burst) / q.rps)
+	}
+}
+
+// Acquire takes one of key's in-flight slots. The returned func gives it
+// back and must be called once the request is done.
+func Acquire(store *Store, sc scenario.Scenario, key string) (func(), bool) {
+	if sc.Concurrency == nil {
+		return func() {}, true
+	}
 
-	if limiter, ok := s.limiters[key]; ok {
-		return limiter
+	sh := store.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.inflight[key] >= sc.Concurrency.Max {
+		return nil, false
 	}
+	sh.inflight[key]++
 
-	limiter := rate.NewLimiter(rate.Limit(rps), burst)
-	s.limiters[key] = limiter
-	return limiter
+	return func() {
+		sh.mu.Lock()
+		defer sh.mu.Unlock()
+		if sh.inflight[key]--; sh.inflight[key] <= 0 {
+			delete(sh.inflight, key)
+		}
+	}, true
 }
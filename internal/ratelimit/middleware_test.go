@@ -1,6 +1,8 @@
 package ratelimit
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"rudeserver/internal/scenario"
@@ -52,3 +54,77 @@ func TestAllowWithoutRateLimit(t *testing.T) {
 		t.Fatal("allow without rate limit should pass")
 	}
 }
+
+func TestAcquireHonorsConcurrency(t *testing.T) {
+	sc := scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		Method:         "GET",
+		NormalizedPath: "/status/200",
+		Concurrency:    &scenario.Concurrency{Max: 2, Status: 503},
+	}
+
+	store := NewStore()
+	release1, ok := Acquire(store, sc, Key(sc, "203.0.113.1"))
+	if !ok {
+		t.Fatal("first acquire should pass")
+	}
+	release2, ok := Acquire(store, sc, Key(sc, "203.0.113.1"))
+	if !ok {
+		t.Fatal("second acquire should pass")
+	}
+	if _, ok := Acquire(store, sc, Key(sc, "203.0.113.1")); ok {
+		t.Fatal("third acquire should be rejected")
+	}
+	if release, ok := Acquire(store, sc, Key(sc, "203.0.113.2")); !ok {
+		t.Fatal("other client should have its own slots")
+	} else {
+		release()
+	}
+
+	release1()
+	release3, ok := Acquire(store, sc, Key(sc, "203.0.113.1"))
+	if !ok {
+		t.Fatal("released slot should be reusable")
+	}
+	release2()
+	release3()
+	if sh := store.shard(Key(sc, "203.0.113.1")); len(sh.inflight) != 0 {
+		t.Fatalf("inflight = %v", sh.inflight)
+	}
+}
+
+func TestAcquireWithoutConcurrency(t *testing.T) {
+	sc := scenario.Scenario{Protocol: scenario.ProtocolHTTP, Method: "GET", NormalizedPath: "/status/200"}
+	release, ok := Acquire(NewStore(), sc, "203.0.113.1")
+	if !ok {
+		t.Fatal("acquire without conc should pass")
+	}
+	release()
+}
+
+func TestLimitKey(t *testing.T) {
+	sc := scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		Method:         "GET",
+		NormalizedPath: "/status/200",
+	}
+	r := httptest.NewRequest(http.MethodGet, "/http/status/200", nil)
+	r.Header.Set("X-Api-Key", "tenant-a")
+
+	tests := []struct {
+		key  scenario.RateKey
+		want string
+	}{
+		{scenario.RateKey{}, "http|GET|/status/200|203.0.113.1"},
+		{scenario.RateKey{Kind: scenario.RateKeyPath}, "http|GET|/status/200"},
+		{scenario.RateKey{Kind: scenario.RateKeyGlobal}, "global"},
+		{scenario.RateKey{Kind: scenario.RateKeyHeader, Name: "X-Api-Key"}, "http|GET|/status/200|X-Api-Key=tenant-a"},
+		{scenario.RateKey{Kind: scenario.RateKeyBucket, Name: "orders"}, "bucket|orders"},
+	}
+	for _, tt := range tests {
+		sc.RateKey = tt.key
+		if got := LimitKey(sc, r, "203.0.113.1"); got != tt.want {
+			t.Fatalf("%+v: key = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
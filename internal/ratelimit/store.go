@@ -0,0 +1,303 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"hash/maphash"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+const (
+	// DefaultMaxEntries bounds the limiters a store keeps.
+	DefaultMaxEntries = 100000
+	// DefaultIdleTTL is how long an unused limiter is kept.
+	DefaultIdleTTL = 10 * time.Minute
+
+	shardCount = 32
+)
+
+// Limits bound a Store. Zero values take the defaults.
+type Limits struct {
+	// MaxEntries caps the limiters kept across all shards. To make room,
+	// the least recently used limiter of the new key's shard is evicted,
+	// or of the next shard if that one holds only the new key.
+	MaxEntries int
+	// IdleTTL drops limiters unused for this long, or for as long as the
+	// limiter needs to refill if that is longer.
+	IdleTTL time.Duration
+}
+
+// Stats counts a store's limiters and how many were dropped.
+type Stats struct {
+	Entries int    `json:"entries"`
+	Evicted uint64 `json:"evicted"`
+	Expired uint64 `json:"expired"`
+}
+
+// KeyInfo describes a key's limiter and in-flight requests as of the
+// time it was taken. Keys only limited by conc have no Algorithm. Tokens
+// is the fractional fill of token buckets, which Remaining rounds down.
+type KeyInfo struct {
+	Key       string                 `json:"key"`
+	Algorithm scenario.RateAlgorithm `json:"algorithm,omitempty"`
+	RPS       float64                `json:"rps,omitempty"`
+	Burst     int                    `json:"burst,omitempty"`
+	Limit     int                    `json:"limit,omitempty"`
+	Window    string                 `json:"window,omitempty"`
+	Remaining int                    `json:"remaining"`
+	Tokens    *float64               `json:"tokens,omitempty"`
+	Reset     string                 `json:"reset,omitempty"`
+	LastSeen  *time.Time             `json:"last_seen,omitempty"`
+	InFlight  int                    `json:"in_flight"`
+}
+
+// Store keeps limiters and in-flight counts by key. Keys are spread over
+// shards so unrelated keys do not contend on one lock.
+type Store struct {
+	shards     [shardCount]shard
+	seed       maphash.Seed
+	maxEntries int64
+	idleTTL    time.Duration
+	entries    atomic.Int64
+	evicted    atomic.Uint64
+	expired    atomic.Uint64
+}
+
+// shard holds its limiters in an LRU list, most recently used first.
+type shard struct {
+	mu       sync.Mutex
+	limiters map[string]*list.Element
+	lru      list.List
+	inflight map[string]int
+}
+
+// entry remembers the quota a limiter was built from, so a key whose
+// scenario changes gets a fresh limiter.
+type entry struct {
+	key      string
+	config   quota
+	limiter  limiter
+	lastUsed time.Time
+}
+
+func NewStore() *Store {
+	return NewStoreWithLimits(Limits{})
+}
+
+func NewStoreWithLimits(limits Limits) *Store {
+	if limits.MaxEntries <= 0 {
+		limits.MaxEntries = DefaultMaxEntries
+	}
+	if limits.IdleTTL <= 0 {
+		limits.IdleTTL = DefaultIdleTTL
+	}
+
+	s := &Store{
+		seed:       maphash.MakeSeed(),
+		maxEntries: int64(limits.MaxEntries),
+		idleTTL:    limits.IdleTTL,
+	}
+	for i := range s.shards {
+		s.shards[i].limiters = make(map[string]*list.Element)
+		s.shards[i].inflight = make(map[string]int)
+	}
+	return s
+}
+
+func (s *Store) shardIndex(key string) int {
+	return int(maphash.String(s.seed, key) % shardCount)
+}
+
+func (s *Store) shard(key string) *shard {
+	return &s.shards[s.shardIndex(key)]
+}
+
+func (s *Store) limiter(key string, config scenario.RateLimit, now time.Time) limiter {
+	q := quotaOf(config)
+	i := s.shardIndex(key)
+	sh := &s.shards[i]
+	sh.mu.Lock()
+
+	if el, ok := sh.limiters[key]; ok {
+		e := el.Value.(*entry)
+		if e.config == q || shared(key) {
+			e.lastUsed = now
+			sh.lru.MoveToFront(el)
+			sh.mu.Unlock()
+			return e.limiter
+		}
+		s.drop(sh, el)
+	}
+
+	e := &entry{key: key, config: q, limiter: newLimiter(config), lastUsed: now}
+	sh.limiters[key] = sh.lru.PushFront(e)
+	s.entries.Add(1)
+	sh.mu.Unlock()
+
+	for s.entries.Load() > s.maxEntries {
+		if !s.evictOne(i) {
+			break
+		}
+	}
+	return e.limiter
+}
+
+// evictOne drops the least recently used limiter of shard i, or of the
+// next shard holding one. Shard i keeps its newest limiter, which is the
+// one just added. Only one shard is locked at a time.
+func (s *Store) evictOne(i int) bool {
+	for n := range shardCount {
+		sh := &s.shards[(i+n)%shardCount]
+		sh.mu.Lock()
+		if sh.lru.Len() > 1 || n > 0 && sh.lru.Len() > 0 {
+			s.drop(sh, sh.lru.Back())
+			sh.mu.Unlock()
+			s.evicted.Add(1)
+			return true
+		}
+		sh.mu.Unlock()
+	}
+	return false
+}
+
+// drop must be called with sh locked.
+func (s *Store) drop(sh *shard, el *list.Element) {
+	delete(sh.limiters, el.Value.(*entry).key)
+	sh.lru.Remove(el)
+	s.entries.Add(-1)
+}
+
+// Sweep drops limiters idle past the store's TTL.
+func (s *Store) Sweep(now time.Time) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for el := sh.lru.Back(); el != nil; {
+			prev := el.Prev()
+			e := el.Value.(*entry)
+			if now.Sub(e.lastUsed) > max(s.idleTTL, e.config.recovery()) {
+				s.drop(sh, el)
+				s.expired.Add(1)
+			}
+			el = prev
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Sweep(now)
+		}
+	}
+}
+
+func (s *Store) Stats() Stats {
+	return Stats{Entries: int(s.entries.Load()), Evicted: s.evicted.Load(), Expired: s.expired.Load()}
+}
+
+// Keys describes every key starting with prefix, sorted by key. Looking
+// does not count as use.
+func (s *Store) Keys(prefix string, now time.Time) []KeyInfo {
+	var infos []KeyInfo
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for key := range sh.limiters {
+			if strings.HasPrefix(key, prefix) {
+				infos = append(infos, sh.info(key, now))
+			}
+		}
+		for key := range sh.inflight {
+			if _, ok := sh.limiters[key]; !ok && strings.HasPrefix(key, prefix) {
+				infos = append(infos, sh.info(key, now))
+			}
+		}
+		sh.mu.Unlock()
+	}
+	slices.SortFunc(infos, func(a, b KeyInfo) int { return strings.Compare(a.Key, b.Key) })
+	return infos
+}
+
+// Lookup describes one key.
+func (s *Store) Lookup(key string, now time.Time) (KeyInfo, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.limiters[key]; !ok && sh.inflight[key] == 0 {
+		return KeyInfo{}, false
+	}
+	return sh.info(key, now), true
+}
+
+// Reset drops the key's limiter, so its next request starts with a full
+// quota. In-flight counts are left alone; they drain as requests finish.
+func (s *Store) Reset(key string) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	el, ok := sh.limiters[key]
+	if ok {
+		s.drop(sh, el)
+	}
+	return ok
+}
+
+// ResetPrefix drops the limiters of every key starting with prefix, or of
+// all keys when prefix is empty, and returns how many there were.
+func (s *Store) ResetPrefix(prefix string) int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for key, el := range sh.limiters {
+			if strings.HasPrefix(key, prefix) {
+				s.drop(sh, el)
+				n++
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// info must be called with the shard locked.
+func (sh *shard) info(key string, now time.Time) KeyInfo {
+	info := KeyInfo{Key: key, InFlight: sh.inflight[key]}
+	el, ok := sh.limiters[key]
+	if !ok {
+		return info
+	}
+	e := el.Value.(*entry)
+	d := e.limiter.decide(now, false)
+	lastSeen := e.lastUsed
+	info.Algorithm = e.config.algorithm
+	if info.Algorithm == "" {
+		info.Algorithm = scenario.RateToken
+	}
+	info.RPS = e.config.rps
+	info.Burst = e.config.burst
+	info.Limit = e.config.limit
+	info.Window = d.Window.String()
+	info.Remaining = d.Remaining
+	if b, ok := e.limiter.(*tokenBucket); ok {
+		tokens := b.TokensAt(now)
+		info.Tokens = &tokens
+	}
+	info.Reset = d.Reset.String()
+	info.LastSeen = &lastSeen
+	return info
+}
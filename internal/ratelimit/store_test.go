@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+func TestStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewStoreWithLimits(Limits{MaxEntries: 10})
+	rl := scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 1, Window: time.Hour}
+
+	for i := range 100 {
+		store.limiter("key-"+strconv.Itoa(i), rl, epoch)
+	}
+	if stats := store.Stats(); stats.Entries != 10 || stats.Evicted != 90 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if infos := store.Keys("", epoch); len(infos) != 10 {
+		t.Fatalf("keys = %d", len(infos))
+	}
+}
+
+func TestStoreKeepsKeysSharingAShard(t *testing.T) {
+	store := NewStoreWithLimits(Limits{MaxEntries: 10})
+	rl := scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 1, Window: time.Hour}
+
+	// Two live keys in one shard stay put while the store has room.
+	first := "key-0"
+	second := ""
+	for i := 1; second == ""; i++ {
+		if key := "key-" + strconv.Itoa(i); store.shardIndex(key) == store.shardIndex(first) {
+			second = key
+		}
+	}
+	a := store.limiter(first, rl, epoch)
+	b := store.limiter(second, rl, epoch)
+	for range 5 {
+		if store.limiter(first, rl, epoch) != a || store.limiter(second, rl, epoch) != b {
+			t.Fatal("keys sharing a shard evicted each other")
+		}
+	}
+	if stats := store.Stats(); stats.Entries != 2 || stats.Evicted != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestStoreKeepsRecentlyUsed(t *testing.T) {
+	store := NewStoreWithLimits(Limits{MaxEntries: 2})
+	rl := scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 1, Window: time.Hour}
+
+	hot := store.limiter("hot", rl, epoch)
+	sh := store.shardIndex("hot")
+	// Cold keys landing in hot's shard evict the older cold key, not hot.
+	for i, added := 0, 0; added < 5; i++ {
+		key := "cold-" + strconv.Itoa(i)
+		if store.shardIndex(key) != sh {
+			continue
+		}
+		store.limiter("hot", rl, epoch)
+		store.limiter(key, rl, epoch)
+		added++
+	}
+	if store.limiter("hot", rl, epoch) != hot {
+		t.Fatal("recently used limiter was evicted")
+	}
+	if stats := store.Stats(); stats.Entries != 2 || stats.Evicted != 4 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestStoreSweepsIdleLimiters(t *testing.T) {
+	store := NewStoreWithLimits(Limits{IdleTTL: time.Minute})
+	short := scenario.RateLimit{RPS: 10, Burst: 10}
+	long := scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 1, Window: time.Hour}
+	store.limiter("short", short, epoch)
+	store.limiter("long", long, epoch)
+
+	store.Sweep(epoch.Add(2 * time.Minute))
+	// The hour window outlives the TTL: dropping it early would reset the quota.
+	if stats := store.Stats(); stats.Entries != 1 || stats.Expired != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	store.Sweep(epoch.Add(2 * time.Hour))
+	if stats := store.Stats(); stats.Entries != 0 || stats.Expired != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestStoreKeysDoNotCountAsUse(t *testing.T) {
+	store := NewStore()
+	rl := scenario.RateLimit{Algorithm: scenario.RateLog, Limit: 2, Window: time.Minute}
+	store.limiter("a", rl, epoch).decide(epoch, true)
+	store.limiter("b", rl, epoch)
+
+	for range 3 {
+		infos := store.Keys("", epoch.Add(time.Second))
+		if len(infos) != 2 || infos[0].Key != "a" || infos[0].Remaining != 1 || infos[1].Remaining != 2 {
+			t.Fatalf("infos = %+v", infos)
+		}
+	}
+	if info, ok := store.Lookup("a", epoch); !ok || info.Reset != "1m0s" || info.Window != "1m0s" {
+		t.Fatalf("info = %+v, ok = %v", info, ok)
+	}
+
+	if !store.Reset("a") || store.Reset("a") {
+		t.Fatal("reset should drop a once")
+	}
+	if n := store.ResetPrefix(""); n != 1 || store.Stats().Entries != 0 {
+		t.Fatalf("reset all = %d, stats = %+v", n, store.Stats())
+	}
+}
+
+func TestStoreKeysReportTokens(t *testing.T) {
+	store := NewStore()
+	store.limiter("token", scenario.RateLimit{RPS: 2, Burst: 4}, epoch).decide(epoch, true)
+	store.limiter("fixed", scenario.RateLimit{Algorithm: scenario.RateFixed, Limit: 1, Window: time.Hour}, epoch)
+
+	info, _ := store.Lookup("token", epoch.Add(250*time.Millisecond))
+	if info.Tokens == nil || *info.Tokens != 3.5 || info.Remaining != 3 {
+		t.Fatalf("token info = %+v", info)
+	}
+	if info, _ := store.Lookup("fixed", epoch); info.Tokens != nil {
+		t.Fatalf("fixed window should not report tokens, got %v", *info.Tokens)
+	}
+}
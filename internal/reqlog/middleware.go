@@ -1,11 +1,15 @@
 package reqlog
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"io"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -14,19 +18,36 @@ import (
 
 const maxBodyBytes = 256 * 1024
 
+// Phases of a response at the moment the client went away.
+const (
+	PhaseWaiting = "waiting"
+	PhaseHeaders = "headers"
+	PhaseBody    = "body"
+)
+
 type responseCapture struct {
 	http.ResponseWriter
 	status      int
 	body        bytes.Buffer
 	wroteHeader bool
+	hijacked    bool
+
+	mu         sync.Mutex
+	written    int64
+	writeErr   error
+	goneAt     time.Time
+	gonePhase  string
+	goneMarked chan struct{}
 }
 
 func (r *responseCapture) WriteHeader(status int) {
 	if r.wroteHeader {
 		return
 	}
+	r.mu.Lock()
 	r.status = status
 	r.wroteHeader = true
+	r.mu.Unlock()
 	r.ResponseWriter.WriteHeader(status)
 }
 
@@ -43,7 +64,48 @@ func (r *responseCapture) Write(p []byte) (int, error) {
 			r.body.Write(p[:remaining])
 		}
 	}
-	return r.ResponseWriter.Write(p)
+	n, err := r.ResponseWriter.Write(p)
+
+	r.mu.Lock()
+	r.written += int64(n)
+	if err != nil && r.writeErr == nil {
+		r.writeErr = err
+	}
+	r.mu.Unlock()
+	return n, err
+}
+
+func (r *responseCapture) Flush() {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// markGone records when the client disconnected and how far the response got.
+func (r *responseCapture) markGone() {
+	defer close(r.goneMarked)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.goneAt = time.Now().UTC()
+	switch {
+	case r.written > 0:
+		r.gonePhase = PhaseBody
+	case r.wroteHeader:
+		r.gonePhase = PhaseHeaders
+	default:
+		r.gonePhase = PhaseWaiting
+	}
 }
 
 func Middleware(store *Store, next http.Handler) http.Handler {
@@ -55,56 +117,99 @@ func Middleware(store *Store, next http.Handler) http.Handler {
 
 		start := time.Now()
 
-		reqBytes, reqTrunc, reqSize, reqErr := readRequestBody(r)
-		if reqBytes != nil {
-			r.Body = io.NopCloser(bytes.NewReader(reqBytes))
+		var reqCapture *requestCapture
+		if r.Body != nil {
+			reqCapture = &requestCapture{ReadCloser: r.Body}
+			r.Body = reqCapture
 		}
 
-		capture := &responseCapture{ResponseWriter: w}
+		capture := &responseCapture{ResponseWriter: w, goneMarked: make(chan struct{})}
+		stop := context.AfterFunc(r.Context(), capture.markGone)
 		next.ServeHTTP(capture, r)
+		if !stop() {
+			<-capture.goneMarked
+		}
+		reqBytes, reqTrunc, reqSize, reqErr := readRequestBody(reqCapture, r.ContentLength)
 
 		entry := Entry{
-			Method:     r.Method,
-			Path:       r.URL.Path,
-			Query:      r.URL.RawQuery,
-			Protocol:   protocolFromPath(r.URL.Path),
-			ClientIP:   ip.ClientIP(r),
-			UserAgent:  r.UserAgent(),
-			Status:     capture.status,
-			Duration:   time.Since(start).Milliseconds(),
-			ReqHeaders: cloneHeaders(r.Header),
-			ResHeaders: cloneHeaders(capture.Header()),
-			ReqBody:    reqBytes,
-			ResBody:    capture.body.Bytes(),
+			Method:       r.Method,
+			Path:         r.URL.Path,
+			Query:        r.URL.RawQuery,
+			Protocol:     protocolFromPath(r.URL.Path),
+			ClientIP:     ip.ClientIP(r),
+			UserAgent:    r.UserAgent(),
+			Status:       capture.status,
+			Duration:     time.Since(start).Milliseconds(),
+			ReqHeaders:   cloneHeaders(r.Header),
+			ResHeaders:   cloneHeaders(capture.Header()),
+			ReqBody:      reqBytes,
+			ResBody:      capture.body.Bytes(),
 			ReqTruncated: reqTrunc,
-			ResTruncated: capture.body.Len() >= maxBodyBytes,
+			ResTruncated: capture.written > int64(capture.body.Len()),
 			ReqSize:      reqSize,
-			ResSize:      int64(capture.body.Len()),
+			ResSize:      capture.written,
 			ContentType:  capture.Header().Get("Content-Type"),
 			ReqError:     reqErr,
 		}
+		if capture.hijacked {
+			entry.ResError = "connection hijacked"
+		}
+		if capture.writeErr != nil {
+			entry.ResError = "write response failed"
+		}
+		if !capture.goneAt.IsZero() {
+			entry.ClientGone = true
+			entry.ClientGoneAt = capture.goneAt
+			entry.ClientGonePhase = capture.gonePhase
+		}
 
 		populateEncoding(&entry)
 		store.Add(entry)
 	})
 }
 
-func readRequestBody(r *http.Request) ([]byte, bool, int64, string) {
-	if r.Body == nil {
-		return nil, false, 0, ""
+// requestCapture records the first maxBodyBytes of the request body as the
+// handler reads it, so downstream handlers control how fast it is consumed.
+type requestCapture struct {
+	io.ReadCloser
+	body bytes.Buffer
+	size int64
+	eof  bool
+	err  error
+}
+
+func (r *requestCapture) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if remaining := maxBodyBytes - r.body.Len(); remaining > 0 {
+			r.body.Write(p[:min(n, remaining)])
+		}
+		r.size += int64(n)
+	}
+	if err == io.EOF {
+		r.eof = true
+	}
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
 	}
+	return n, err
+}
 
-	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
-	if err != nil {
+// readRequestBody reports what the handler read of the body. Nothing more
+// is read from the client once the response is done, since a stalled
+// upload would hold the handler; a body the handler left unread is
+// reported as truncated.
+func readRequestBody(capture *requestCapture, contentLength int64) ([]byte, bool, int64, string) {
+	if capture == nil {
+		return nil, false, 0, ""
+	}
+	if capture.err != nil {
 		return nil, false, 0, "read request body failed"
 	}
 
-	truncated := false
-	if len(body) > maxBodyBytes {
-		truncated = true
-		body = body[:maxBodyBytes]
-	}
-	return body, truncated, int64(len(body)), ""
+	body := capture.body.Bytes()
+	unread := !capture.eof && (contentLength < 0 || capture.size < contentLength)
+	return body, capture.size > maxBodyBytes || unread, int64(len(body)), ""
 }
 
 func protocolFromPath(path string) string {
@@ -2,6 +2,7 @@ package reqlog
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -68,6 +69,7 @@ func TestMiddlewareExcludesUIAndOpenAPI(t *testing.T) {
 func TestMiddlewareTruncatesBodies(t *testing.T) {
 	store := NewStore(10)
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
 		w.WriteHeader(200)
 		_, _ = w.Write(bytes.Repeat([]byte("b"), maxBodyBytes+10))
 	})
@@ -102,3 +104,170 @@ func TestMiddlewareSetsProtocol(t *testing.T) {
 		t.Fatalf("protocol = %q", entry.Protocol)
 	}
 }
+
+func TestMiddlewareRecordsClientGonePhase(t *testing.T) {
+	cases := []struct {
+		name    string
+		respond func(w http.ResponseWriter)
+		phase   string
+	}{
+		{"waiting", func(w http.ResponseWriter) {}, PhaseWaiting},
+		{"headers", func(w http.ResponseWriter) { w.WriteHeader(200) }, PhaseHeaders},
+		{"body", func(w http.ResponseWriter) { _, _ = w.Write([]byte("partial")) }, PhaseBody},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewStore(10)
+			ctx, cancel := context.WithCancel(context.Background())
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.respond(w)
+				cancel()
+				<-r.Context().Done()
+			})
+			wrapped := Middleware(store, h)
+
+			req := httptest.NewRequest(http.MethodGet, "/http/status/200", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			wrapped.ServeHTTP(rec, req)
+
+			entry := store.List()[0]
+			if !entry.ClientGone || entry.ClientGoneAt.IsZero() {
+				t.Fatalf("entry = %+v", entry)
+			}
+			if entry.ClientGonePhase != tc.phase {
+				t.Fatalf("phase = %q, want %q", entry.ClientGonePhase, tc.phase)
+			}
+		})
+	}
+}
+
+func TestMiddlewareClientStayed(t *testing.T) {
+	store := NewStore(10)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	wrapped := Middleware(store, h)
+
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200", nil)
+	rec := httptest.NewRecorder()
+	wrapped.ServeHTTP(rec, req)
+
+	if entry := store.List()[0]; entry.ClientGone || entry.ClientGonePhase != "" {
+		t.Fatalf("entry = %+v", entry)
+	}
+}
+
+func TestMiddlewarePassesHijackThrough(t *testing.T) {
+	store := NewStore(10)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		conn.Close()
+	})
+	srv := httptest.NewServer(Middleware(store, h))
+	defer srv.Close()
+
+	if _, err := http.Get(srv.URL + "/http/status/200"); err == nil {
+		t.Fatal("expected transport error")
+	}
+	entries := store.List()
+	if len(entries) != 1 || entries[0].ResError != "connection hijacked" {
+		t.Fatalf("entries = %+v", entries)
+	}
+}
+
+func TestMiddlewarePassesFlushThrough(t *testing.T) {
+	store := NewStore(10)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("a"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush: %v", err)
+		}
+		_, _ = w.Write([]byte("b"))
+	})
+	wrapped := Middleware(store, h)
+
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200", nil)
+	rec := httptest.NewRecorder()
+	wrapped.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Fatal("expected flush to reach the underlying writer")
+	}
+	if got := string(store.List()[0].ResBody); got != "ab" {
+		t.Fatalf("res body = %q", got)
+	}
+}
+
+func TestMiddlewarePassesFullBodyDownstream(t *testing.T) {
+	store := NewStore(10)
+	payload := bytes.Repeat([]byte("a"), maxBodyBytes+10)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) != len(payload) {
+			t.Errorf("downstream body len = %d", len(body))
+		}
+		w.WriteHeader(200)
+	})
+	wrapped := Middleware(store, h)
+
+	req := httptest.NewRequest(http.MethodPost, "/http/status/200", bytes.NewReader(payload))
+	rec := httptest.NewRecorder()
+	wrapped.ServeHTTP(rec, req)
+
+	entry := store.List()[0]
+	if !entry.ReqTruncated || len(entry.ReqBody) != maxBodyBytes {
+		t.Fatalf("truncated = %v, len = %d", entry.ReqTruncated, len(entry.ReqBody))
+	}
+}
+
+func TestMiddlewareDoesNotDrainUnreadBodies(t *testing.T) {
+	store := NewStore(10)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadFull(r.Body, make([]byte, 3))
+		w.WriteHeader(200)
+	})
+	wrapped := Middleware(store, h)
+
+	body := &countingReader{Reader: strings.NewReader("abcdef")}
+	req := httptest.NewRequest(http.MethodPost, "/http/status/200", body)
+	wrapped.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := store.List()[0]
+	if string(entry.ReqBody) != "abc" || !entry.ReqTruncated || body.n != 3 {
+		t.Fatalf("body = %q, truncated = %v, read = %d", entry.ReqBody, entry.ReqTruncated, body.n)
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestMiddlewareReportsFullResponseSize(t *testing.T) {
+	store := NewStore(10)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			_, _ = w.Write(bytes.Repeat([]byte("c"), maxBodyBytes))
+		}
+	})
+	wrapped := Middleware(store, h)
+
+	req := httptest.NewRequest(http.MethodGet, "/http/status/200?size=768KB", nil)
+	rec := httptest.NewRecorder()
+	wrapped.ServeHTTP(rec, req)
+
+	entry := store.List()[0]
+	if entry.ResSize != 3*maxBodyBytes || len(entry.ResBody) != maxBodyBytes || !entry.ResTruncated {
+		t.Fatalf("size = %d, stored = %d, truncated = %v", entry.ResSize, len(entry.ResBody), entry.ResTruncated)
+	}
+}
@@ -19,12 +19,12 @@ type Entry struct {
 	ReqHeaders map[string][]string
 	ResHeaders map[string][]string
 
-	ReqBody       []byte
-	ResBody       []byte
-	ReqTruncated  bool
-	ResTruncated  bool
-	ReqSize       int64
-	ResSize       int64
+	ReqBody      []byte
+	ResBody      []byte
+	ReqTruncated bool
+	ResTruncated bool
+	ReqSize      int64
+	ResSize      int64
 	ContentType  string
 	ReqBodyIsUTF bool
 	ResBodyIsUTF bool
@@ -33,6 +33,10 @@ type Entry struct {
 	ReqError     string
 	ResError     string
 	UserAgent    string
+
+	ClientGone      bool
+	ClientGoneAt    time.Time
+	ClientGonePhase string
 }
 
 type Store struct {
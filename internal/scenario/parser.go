@@ -1,26 +1,82 @@
 package scenario
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var ErrUnsupportedProtocol = errors.New("unsupported protocol")
+
+// HeaderPrefix marks request headers that carry scenario parameters, for
+// clients that cannot change the URL: X-Rude-Fail-Status sets fail_status
+// and X-Rude-Status the status code. Anything in the URL wins.
+const HeaderPrefix = "X-Rude-"
+
 func ParseRequest(r *http.Request) (Scenario, error) {
 	if r == nil || r.URL == nil {
 		return Scenario{}, fmt.Errorf("request is nil")
 	}
+	if strings.HasPrefix(r.URL.Path, TokenPrefix) {
+		return parseToken(r.Method, r.URL.Path)
+	}
 
-	protocol, normalizedPath, status := parsePath(r.URL.Path)
+	protocol, normalizedPath, status, hops := parsePath(r.URL.Path)
 	if protocol == "" {
-		return Scenario{}, fmt.Errorf("unsupported protocol")
+		return Scenario{}, ErrUnsupportedProtocol
+	}
+	if status == 0 {
+		status = 200
+		if raw := r.Header.Get(HeaderPrefix + "Status"); raw != "" {
+			code, err := strconv.Atoi(raw)
+			if err != nil || code < 100 || code > 599 {
+				return Scenario{}, fmt.Errorf("invalid status")
+			}
+			status = code
+		}
 	}
 
 	q := r.URL.Query()
-	delay, err := parseDelay(q.Get("delay"))
+	for name, values := range headerValues(r.Header) {
+		if _, ok := q[name]; !ok {
+			q[name] = values
+		}
+	}
+	return parseValues(protocol, r.Method, normalizedPath, status, hops, q)
+}
+
+// headerValues maps X-Rude-* headers to query parameter names, e.g.
+// X-Rude-Chunk-Delay to chunk_delay. Repeated headers keep every value.
+func headerValues(h http.Header) url.Values {
+	q := make(url.Values)
+	for key, values := range h {
+		name, ok := strings.CutPrefix(http.CanonicalHeaderKey(key), HeaderPrefix)
+		if !ok || name == "" || name == "Status" {
+			continue
+		}
+		name = strings.ReplaceAll(strings.ToLower(name), "-", "_")
+		q[name] = append(q[name], values...)
+	}
+	return q
+}
+
+// parseValues builds a scenario from smart-URL query parameters.
+func parseValues(protocol Protocol, method string, normalizedPath string, status int, hops int, q url.Values) (Scenario, error) {
+	redirect, err := parseRedirect(protocol, hops, q)
+	if err != nil {
+		return Scenario{}, err
+	}
+	if redirect != nil {
+		status = redirect.Codes[(redirect.Start-redirect.Hops)%len(redirect.Codes)]
+	}
+
+	delay, delayDist, err := parseDelay(q.Get("delay"))
 	if err != nil {
 		return Scenario{}, err
 	}
@@ -30,7 +86,87 @@ func ParseRequest(r *http.Request) (Scenario, error) {
 		return Scenario{}, err
 	}
 
-	rateLimit, err := parseRateLimit(q.Get("rl"), q.Get("burst"))
+	rateLimit, err := parseRateLimit(q.Get("rl"), q.Get("burst"), q.Get("rl_algo"), q.Get("rl_window"))
+	if err != nil {
+		return Scenario{}, err
+	}
+	if err := parseRateHeaders(rateLimit, q.Get("rl_headers"), q.Get("rl_retry")); err != nil {
+		return Scenario{}, err
+	}
+	if err := parseRateReject(rateLimit, q.Get("rl_status"), q.Get("rl_body"), q["rl_h"], q.Get("rl_delay")); err != nil {
+		return Scenario{}, err
+	}
+
+	concurrency, err := parseConcurrency(q.Get("conc"), q.Get("conc_status"))
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	rateKey, err := parseRateKey(q.Get("rl_key"), rateLimit != nil || concurrency != nil)
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	sequence, err := parseSequence(q.Get("seq"), q.Get("seq_mode"))
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	failure, err := parseFailure(q.Get("fail"), q.Get("fail_status"))
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	seed, err := parseSeed(q.Get("seed"))
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	fault, err := parseFault(q.Get("fault"))
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	stream, err := parseStream(q.Get("chunks"), q.Get("chunk_delay"))
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	bwDown, err := parseBandwidth(q.Get("bw_down"), "bw_down")
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	bwUp, err := parseBandwidth(q.Get("bw_up"), "bw_up")
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	generator, err := parseGenerator(q.Get("size"), q.Get("json_size"), q.Get("fill"))
+	if err != nil {
+		return Scenario{}, err
+	}
+	if generator != nil && protocol == ProtocolJSONRPC {
+		// JSON-RPC results are built in memory.
+		if generator.Size < 0 {
+			return Scenario{}, fmt.Errorf("endless body unsupported for jsonrpc")
+		}
+		if generator.Size > maxJSONRPCSize {
+			return Scenario{}, fmt.Errorf("size too large for jsonrpc")
+		}
+	}
+
+	template, err := parseBool(q.Get("tmpl"), "tmpl")
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	schedule, err := parseSchedule(q)
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	capacity, err := parseCapacity(q.Get("workers"), q.Get("queue"), q.Get("overload"), q.Get("contention"))
 	if err != nil {
 		return Scenario{}, err
 	}
@@ -39,21 +175,181 @@ func ParseRequest(r *http.Request) (Scenario, error) {
 
 	return Scenario{
 		Protocol:       protocol,
-		Method:         r.Method,
+		Method:         method,
 		NormalizedPath: normalizedPath,
 		StatusCode:     status,
 		Delay:          delay,
+		DelayDist:      delayDist,
 		RateLimit:      rateLimit,
+		Concurrency:    concurrency,
+		RateKey:        rateKey,
 		Headers:        headers,
 		Body:           body,
+		Sequence:       sequence,
+		Failure:        failure,
+		Seed:           seed,
+		Fault:          fault,
+		Stream:         stream,
+		BandwidthDown:  bwDown,
+		BandwidthUp:    bwUp,
+		Generator:      generator,
+		Template:       template,
+		Redirect:       redirect,
+		Schedule:       schedule,
+		Capacity:       capacity,
 	}, nil
 }
 
-func parsePath(path string) (Protocol, string, int) {
+// maxJSONRPCSize caps generated JSON-RPC results, which are held in memory.
+const maxJSONRPCSize = 16 << 20
+
+// maxCronWindow caps cron_for, which bounds the backwards scan for a
+// matching minute.
+const maxCronWindow = 24 * time.Hour
+
+// parseSchedule reads outage ("10s..40s", "..30s" or "1m.." after the
+// first request), flap ("UP[,DOWN]"), cron with cron_for (default 1m),
+// sched_status (default 503) and sched_key ("route" or "global").
+func parseSchedule(q url.Values) (*Schedule, error) {
+	outageRaw, flapRaw, cronRaw := q.Get("outage"), q.Get("flap"), q.Get("cron")
+	if outageRaw == "" && flapRaw == "" && cronRaw == "" {
+		if q.Get("cron_for") != "" || q.Get("sched_status") != "" || q.Get("sched_key") != "" {
+			return nil, fmt.Errorf("schedule parameters require outage, flap or cron")
+		}
+		return nil, nil
+	}
+
+	schedule := &Schedule{Status: http.StatusServiceUnavailable}
+	if outageRaw != "" {
+		startRaw, endRaw, ok := strings.Cut(outageRaw, "..")
+		if !ok {
+			return nil, fmt.Errorf("invalid outage")
+		}
+		window := &Window{}
+		var err error
+		if startRaw != "" {
+			if window.Start, err = time.ParseDuration(startRaw); err != nil || window.Start < 0 {
+				return nil, fmt.Errorf("invalid outage")
+			}
+		}
+		if endRaw != "" {
+			if window.End, err = time.ParseDuration(endRaw); err != nil || window.End <= window.Start {
+				return nil, fmt.Errorf("invalid outage")
+			}
+		}
+		schedule.Outage = window
+	}
+
+	if flapRaw != "" {
+		upRaw, downRaw, hasDown := strings.Cut(flapRaw, ",")
+		up, err := time.ParseDuration(upRaw)
+		if err != nil || up <= 0 {
+			return nil, fmt.Errorf("invalid flap")
+		}
+		down := up
+		if hasDown {
+			if down, err = time.ParseDuration(downRaw); err != nil || down <= 0 {
+				return nil, fmt.Errorf("invalid flap")
+			}
+		}
+		schedule.FlapUp, schedule.FlapDown = up, down
+	}
+
+	if cronRaw != "" {
+		cron, err := parseCron(cronRaw)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Cron = cron
+		schedule.CronFor = time.Minute
+		if raw := q.Get("cron_for"); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 || d > maxCronWindow {
+				return nil, fmt.Errorf("invalid cron_for")
+			}
+			schedule.CronFor = d
+		}
+	} else if q.Get("cron_for") != "" {
+		return nil, fmt.Errorf("cron_for requires cron")
+	}
+
+	if raw := q.Get("sched_status"); raw != "" {
+		code, err := strconv.Atoi(raw)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid sched_status")
+		}
+		schedule.Status = code
+	}
+
+	switch q.Get("sched_key") {
+	case "", "route":
+	case "global":
+		schedule.Global = true
+	default:
+		return nil, fmt.Errorf("invalid sched_key")
+	}
+	return schedule, nil
+}
+
+const (
+	maxWorkers    = 10000
+	maxQueue      = 100000
+	maxContention = 100
+)
+
+// parseCapacity reads workers, queue (default 0), overload (a status,
+// default 503, or "shed") and contention (default 0, at most 100).
+func parseCapacity(workersRaw string, queueRaw string, overloadRaw string, contentionRaw string) (*Capacity, error) {
+	if workersRaw == "" {
+		if queueRaw != "" || overloadRaw != "" || contentionRaw != "" {
+			return nil, fmt.Errorf("queue, overload and contention require workers")
+		}
+		return nil, nil
+	}
+
+	workers, err := strconv.Atoi(workersRaw)
+	if err != nil || workers <= 0 || workers > maxWorkers {
+		return nil, fmt.Errorf("invalid workers")
+	}
+	capacity := &Capacity{Workers: workers, Status: http.StatusServiceUnavailable}
+
+	if queueRaw != "" {
+		queue, err := strconv.Atoi(queueRaw)
+		if err != nil || queue < 0 || queue > maxQueue {
+			return nil, fmt.Errorf("invalid queue")
+		}
+		capacity.Queue = queue
+	}
+
+	switch overloadRaw {
+	case "":
+	case "shed":
+		capacity.Shed = true
+	default:
+		code, err := strconv.Atoi(overloadRaw)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid overload")
+		}
+		capacity.Status = code
+	}
+
+	if contentionRaw != "" {
+		contention, err := strconv.ParseFloat(contentionRaw, 64)
+		if err != nil || !(contention >= 0) || contention > maxContention {
+			return nil, fmt.Errorf("invalid contention")
+		}
+		capacity.Contention = contention
+	}
+	return capacity, nil
+}
+
+// parsePath extracts the protocol, the path below it, the status from
+// /status/{code} and the hop count from /redirect/{n} (0 when absent).
+func parsePath(path string) (Protocol, string, int, int) {
 	trimmed := strings.TrimPrefix(path, "/")
 	segments := strings.Split(trimmed, "/")
 	if len(segments) == 0 || segments[0] == "" {
-		return "", "", 200
+		return "", "", 0, 0
 	}
 
 	var protocol Protocol
@@ -65,7 +361,7 @@ func parsePath(path string) (Protocol, string, int) {
 	case string(ProtocolJSONRPC):
 		protocol = ProtocolJSONRPC
 	default:
-		return "", "", 200
+		return "", "", 0, 0
 	}
 
 	normalized := "/"
@@ -73,25 +369,107 @@ func parsePath(path string) (Protocol, string, int) {
 		normalized = "/" + strings.Join(segments[1:], "/")
 	}
 
-	status := 200
+	status := 0
+	hops := 0
 	if len(segments) >= 3 && segments[1] == "status" {
 		if code, err := strconv.Atoi(segments[2]); err == nil {
 			status = code
 		}
 	}
+	if len(segments) >= 3 && segments[1] == "redirect" {
+		if n, err := strconv.Atoi(segments[2]); err == nil && n > 0 {
+			hops = n
+		}
+	}
 
-	return protocol, normalized, status
+	return protocol, normalized, status, hops
 }
 
-func parseDelay(raw string) (time.Duration, error) {
+// parseDelay accepts a fixed Go duration or a distribution:
+// "100ms..500ms" (uniform), "normal:MEAN,STDDEV", "lognormal:MEAN,STDDEV"
+// or "p50=50ms,p99=2s" (percentiles).
+func parseDelay(raw string) (time.Duration, *Distribution, error) {
 	if raw == "" {
-		return 0, nil
+		return 0, nil, nil
+	}
+
+	if minRaw, maxRaw, ok := strings.Cut(raw, ".."); ok {
+		min, err1 := time.ParseDuration(minRaw)
+		max, err2 := time.ParseDuration(maxRaw)
+		if err1 != nil || err2 != nil || min < 0 || max < min {
+			return 0, nil, fmt.Errorf("invalid delay range")
+		}
+		return 0, &Distribution{Kind: DistUniform, Min: min, Max: max}, nil
 	}
+
+	if kind, params, ok := strings.Cut(raw, ":"); ok {
+		dist, err := parseMeanStdDev(DistributionKind(kind), params)
+		if err != nil {
+			return 0, nil, err
+		}
+		return 0, dist, nil
+	}
+
+	if strings.HasPrefix(raw, "p") {
+		dist, err := parsePercentiles(raw)
+		if err != nil {
+			return 0, nil, err
+		}
+		return 0, dist, nil
+	}
+
 	parsed, err := time.ParseDuration(raw)
 	if err != nil {
-		return 0, fmt.Errorf("invalid delay")
+		return 0, nil, fmt.Errorf("invalid delay")
 	}
-	return parsed, nil
+	return parsed, nil, nil
+}
+
+func parseMeanStdDev(kind DistributionKind, raw string) (*Distribution, error) {
+	if kind != DistNormal && kind != DistLogNormal {
+		return nil, fmt.Errorf("invalid delay distribution")
+	}
+
+	meanRaw, stddevRaw, ok := strings.Cut(raw, ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid delay distribution")
+	}
+	mean, err1 := time.ParseDuration(meanRaw)
+	stddev, err2 := time.ParseDuration(stddevRaw)
+	if err1 != nil || err2 != nil || mean < 0 || stddev < 0 {
+		return nil, fmt.Errorf("invalid delay distribution")
+	}
+	if kind == DistLogNormal && mean == 0 {
+		return nil, fmt.Errorf("lognormal delay requires a positive mean")
+	}
+	return &Distribution{Kind: kind, Mean: mean, StdDev: stddev}, nil
+}
+
+func parsePercentiles(raw string) (*Distribution, error) {
+	var points []Percentile
+	for _, part := range strings.Split(raw, ",") {
+		rankRaw, valueRaw, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !strings.HasPrefix(rankRaw, "p") {
+			return nil, fmt.Errorf("invalid delay percentile")
+		}
+		rank, err := strconv.ParseFloat(strings.TrimPrefix(rankRaw, "p"), 64)
+		if err != nil || rank <= 0 || rank > 100 {
+			return nil, fmt.Errorf("invalid delay percentile")
+		}
+		value, err := time.ParseDuration(valueRaw)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid delay percentile")
+		}
+		points = append(points, Percentile{Rank: rank, Value: value})
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Rank < points[j].Rank })
+	for i := 1; i < len(points); i++ {
+		if points[i].Rank == points[i-1].Rank || points[i].Value < points[i-1].Value {
+			return nil, fmt.Errorf("delay percentiles must increase")
+		}
+	}
+	return &Distribution{Kind: DistPercentile, Percentiles: points}, nil
 }
 
 func parseHeaders(values []string) (http.Header, error) {
@@ -111,14 +489,118 @@ func parseHeaders(values []string) (http.Header, error) {
 	return headers, nil
 }
 
-func parseRateLimit(rlRaw string, burstRaw string) (*RateLimit, error) {
-	if rlRaw == "" && burstRaw == "" {
+// maxRateLog caps the requests per window of the sliding log, which keeps
+// one timestamp per request.
+const maxRateLog = 100000
+
+// parseRateLimit accepts rl with burst for the token bucket (rl_algo=token,
+// the default), or rl requests per rl_window (default 1s) for the fixed,
+// sliding and log window algorithms.
+func parseRateLimit(rlRaw string, burstRaw string, algoRaw string, windowRaw string) (*RateLimit, error) {
+	if rlRaw == "" {
+		if burstRaw != "" {
+			return nil, fmt.Errorf("burst requires rl")
+		}
+		if algoRaw != "" || windowRaw != "" {
+			return nil, fmt.Errorf("rl_algo and rl_window require rl")
+		}
 		return nil, nil
 	}
-	if rlRaw == "" && burstRaw != "" {
-		return nil, fmt.Errorf("burst requires rl")
+
+	algo := RateAlgorithm(algoRaw)
+	switch algo {
+	case "", RateToken:
+		if windowRaw != "" {
+			return nil, fmt.Errorf("rl_window requires a window rl_algo")
+		}
+		return parseTokenBucket(rlRaw, burstRaw)
+	case RateFixed, RateSliding, RateLog:
+	default:
+		return nil, fmt.Errorf("invalid rl_algo")
+	}
+
+	if burstRaw != "" {
+		return nil, fmt.Errorf("burst requires rl_algo=token")
+	}
+	limit, err := strconv.Atoi(rlRaw)
+	if err != nil || limit <= 0 || algo == RateLog && limit > maxRateLog {
+		return nil, fmt.Errorf("invalid rl")
+	}
+	window := time.Second
+	if windowRaw != "" {
+		window, err = time.ParseDuration(windowRaw)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid rl_window")
+		}
+	}
+	return &RateLimit{Algorithm: algo, Limit: limit, Window: window}, nil
+}
+
+// parseRateHeaders reads rl_headers (all, x, ietf or none; default all)
+// and rl_retry (seconds or date; default seconds).
+func parseRateHeaders(rl *RateLimit, headersRaw string, retryRaw string) error {
+	if rl == nil {
+		if headersRaw != "" || retryRaw != "" {
+			return fmt.Errorf("rl_headers and rl_retry require rl")
+		}
+		return nil
+	}
+
+	rl.Headers = RateHeadersAll
+	switch h := RateHeaders(headersRaw); h {
+	case "":
+	case RateHeadersAll, RateHeadersX, RateHeadersIETF, RateHeadersNone:
+		rl.Headers = h
+	default:
+		return fmt.Errorf("invalid rl_headers")
+	}
+
+	switch retryRaw {
+	case "", "seconds":
+	case "date":
+		rl.RetryDate = true
+	default:
+		return fmt.Errorf("invalid rl_retry")
+	}
+	return nil
+}
+
+// parseRateReject reads rl_status (default 429), rl_body, rl_h headers
+// ("Name:Value", repeatable) and rl_delay, a wait before rejecting.
+func parseRateReject(rl *RateLimit, statusRaw string, body string, headersRaw []string, delayRaw string) error {
+	if rl == nil {
+		if statusRaw != "" || body != "" || len(headersRaw) > 0 || delayRaw != "" {
+			return fmt.Errorf("rl_status, rl_body, rl_h and rl_delay require rl")
+		}
+		return nil
+	}
+
+	rl.Reject = RateReject{Status: http.StatusTooManyRequests, Body: body}
+	if statusRaw != "" {
+		status, err := strconv.Atoi(statusRaw)
+		if err != nil || status < 100 || status > 599 {
+			return fmt.Errorf("invalid rl_status")
+		}
+		rl.Reject.Status = status
+	}
+
+	headers, err := parseHeaders(headersRaw)
+	if err != nil {
+		return fmt.Errorf("invalid rl_h")
+	}
+	rl.Reject.Headers = headers
+
+	if delayRaw != "" {
+		d, err := time.ParseDuration(delayRaw)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid rl_delay")
+		}
+		rl.Reject.Delay = d
 	}
+	return nil
+}
 
+func parseTokenBucket(rlRaw string, burstRaw string) (*RateLimit, error) {
 	rps, err := strconv.ParseFloat(rlRaw, 64)
 	if err != nil || rps <= 0 {
 		return nil, fmt.Errorf("invalid rl")
@@ -138,5 +620,370 @@ func parseRateLimit(rlRaw string, burstRaw string) (*RateLimit, error) {
 		}
 	}
 
-	return &RateLimit{RPS: rps, Burst: burst}, nil
+	return &RateLimit{Algorithm: RateToken, RPS: rps, Burst: burst}, nil
+}
+
+// parseRateKey reads rl_key: ip (the default), path, global,
+// header:NAME or bucket:NAME. It applies to both rl and conc.
+func parseRateKey(raw string, limited bool) (RateKey, error) {
+	if raw == "" {
+		return RateKey{}, nil
+	}
+	if !limited {
+		return RateKey{}, fmt.Errorf("rl_key requires rl or conc")
+	}
+
+	kind, name, _ := strings.Cut(raw, ":")
+	key := RateKey{Kind: RateKeyKind(kind), Name: strings.TrimSpace(name)}
+	switch key.Kind {
+	case RateKeyIP, RateKeyPath, RateKeyGlobal:
+		if name != "" {
+			return RateKey{}, fmt.Errorf("invalid rl_key")
+		}
+	case RateKeyHeader:
+		if key.Name == "" {
+			return RateKey{}, fmt.Errorf("invalid rl_key header")
+		}
+		key.Name = http.CanonicalHeaderKey(key.Name)
+	case RateKeyBucket:
+		if key.Name == "" {
+			return RateKey{}, fmt.Errorf("invalid rl_key bucket")
+		}
+	default:
+		return RateKey{}, fmt.Errorf("invalid rl_key")
+	}
+	return key, nil
+}
+
+// parseConcurrency accepts a max in-flight count and an optional 4xx or 5xx
+// rejection status (default 429).
+func parseConcurrency(concRaw string, statusRaw string) (*Concurrency, error) {
+	if concRaw == "" {
+		if statusRaw != "" {
+			return nil, fmt.Errorf("conc_status requires conc")
+		}
+		return nil, nil
+	}
+
+	max, err := strconv.Atoi(concRaw)
+	if err != nil || max <= 0 {
+		return nil, fmt.Errorf("invalid conc")
+	}
+
+	status := http.StatusTooManyRequests
+	if statusRaw != "" {
+		status, err = strconv.Atoi(statusRaw)
+		if err != nil || status < 400 || status > 599 {
+			return nil, fmt.Errorf("invalid conc_status")
+		}
+	}
+	return &Concurrency{Max: max, Status: status}, nil
+}
+
+const maxSequenceSteps = 1000
+
+// parseSequence accepts comma-separated steps of the form CODE[xN][:BODY],
+// e.g. "503,503,200" or "500x3,200:ok".
+func parseSequence(raw string, mode string) (*Sequence, error) {
+	if raw == "" {
+		if mode != "" {
+			return nil, fmt.Errorf("seq_mode requires seq")
+		}
+		return nil, nil
+	}
+
+	wrap, err := parseSequenceMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []Step
+	for _, part := range strings.Split(raw, ",") {
+		head, body, _ := strings.Cut(strings.TrimSpace(part), ":")
+		codeRaw, repeatRaw, hasRepeat := strings.Cut(head, "x")
+
+		code, err := strconv.Atoi(codeRaw)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid seq status")
+		}
+
+		repeat := 1
+		if hasRepeat {
+			repeat, err = strconv.Atoi(repeatRaw)
+			if err != nil || repeat <= 0 {
+				return nil, fmt.Errorf("invalid seq repeat")
+			}
+		}
+		if len(steps)+repeat > maxSequenceSteps {
+			return nil, fmt.Errorf("seq too long")
+		}
+
+		for i := 0; i < repeat; i++ {
+			steps = append(steps, Step{StatusCode: code, Body: body})
+		}
+	}
+
+	return &Sequence{Steps: steps, Wrap: wrap}, nil
+}
+
+func parseSequenceMode(mode string) (bool, error) {
+	switch mode {
+	case "", "stick":
+		return false, nil
+	case "wrap":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid seq_mode")
+	}
+}
+
+// maxFailWeight caps fail_status weights so their sum cannot overflow.
+const maxFailWeight = 1000000
+
+// parseFailure accepts a probability in [0,1] and an optional weighted status
+// list of the form CODE[:WEIGHT],... (e.g. "500:2,503:1").
+func parseFailure(probRaw string, statusRaw string) (*Failure, error) {
+	if probRaw == "" && statusRaw == "" {
+		return nil, nil
+	}
+	if probRaw == "" && statusRaw != "" {
+		return nil, fmt.Errorf("fail_status requires fail")
+	}
+
+	prob, err := strconv.ParseFloat(probRaw, 64)
+	if err != nil || prob < 0 || prob > 1 {
+		return nil, fmt.Errorf("invalid fail")
+	}
+
+	statuses := []WeightedStatus{{StatusCode: http.StatusInternalServerError, Weight: 1}}
+	if statusRaw != "" {
+		statuses = statuses[:0]
+		for _, part := range strings.Split(statusRaw, ",") {
+			codeRaw, weightRaw, hasWeight := strings.Cut(strings.TrimSpace(part), ":")
+			code, err := strconv.Atoi(codeRaw)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid fail_status")
+			}
+			weight := 1
+			if hasWeight {
+				weight, err = strconv.Atoi(weightRaw)
+				if err != nil || weight <= 0 || weight > maxFailWeight {
+					return nil, fmt.Errorf("invalid fail_status weight")
+				}
+			}
+			statuses = append(statuses, WeightedStatus{StatusCode: code, Weight: weight})
+		}
+	}
+
+	return &Failure{Probability: prob, Statuses: statuses}, nil
+}
+
+func parseSeed(raw string) (*int64, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	seed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed")
+	}
+	return &seed, nil
+}
+
+func parseFault(raw string) (Fault, error) {
+	switch fault := Fault(raw); fault {
+	case "", FaultReset, FaultClose, FaultHang, FaultHeaders, FaultTruncate:
+		return fault, nil
+	default:
+		return "", fmt.Errorf("invalid fault")
+	}
+}
+
+func parseStream(chunksRaw string, delayRaw string) (*Stream, error) {
+	if chunksRaw == "" && delayRaw == "" {
+		return nil, nil
+	}
+	if chunksRaw == "" && delayRaw != "" {
+		return nil, fmt.Errorf("chunk_delay requires chunks")
+	}
+
+	chunks, err := strconv.Atoi(chunksRaw)
+	if err != nil || chunks <= 0 {
+		return nil, fmt.Errorf("invalid chunks")
+	}
+
+	var delay time.Duration
+	if delayRaw != "" {
+		delay, err = time.ParseDuration(delayRaw)
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid chunk_delay")
+		}
+	}
+
+	return &Stream{Chunks: chunks, Delay: delay}, nil
+}
+
+// parseBandwidth parses a bytes-per-second limit such as "1024" or "64KB";
+// zero means unlimited.
+func parseBandwidth(raw string, name string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	bps, err := ParseSize(raw)
+	if err != nil || bps <= 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return bps, nil
+}
+
+func parseGenerator(sizeRaw string, jsonSizeRaw string, fillRaw string) (*Generator, error) {
+	if sizeRaw == "" && jsonSizeRaw == "" {
+		if fillRaw != "" {
+			return nil, fmt.Errorf("fill requires size")
+		}
+		return nil, nil
+	}
+	if sizeRaw != "" && jsonSizeRaw != "" {
+		return nil, fmt.Errorf("size and json_size are exclusive")
+	}
+
+	if jsonSizeRaw != "" {
+		if fillRaw != "" {
+			return nil, fmt.Errorf("fill requires size")
+		}
+		size, err := ParseSize(jsonSizeRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json_size")
+		}
+		return &Generator{Kind: GenJSON, Size: size}, nil
+	}
+
+	size := int64(-1)
+	if sizeRaw != "inf" {
+		parsed, err := ParseSize(sizeRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size")
+		}
+		size = parsed
+	}
+
+	fill := Fill(fillRaw)
+	switch fill {
+	case "":
+		fill = FillRepeat
+	case FillRepeat, FillRandom:
+	default:
+		return nil, fmt.Errorf("invalid fill")
+	}
+
+	return &Generator{Kind: GenBytes, Size: size, Fill: fill}, nil
+}
+
+var sizeUnits = []struct {
+	suffix string
+	mult   float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// ParseSize parses a byte count with an optional binary unit suffix,
+// e.g. "512", "64KB", "1.5MiB" or "10MB".
+func ParseSize(raw string) (int64, error) {
+	num, mult := raw, 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(raw), strings.ToUpper(unit.suffix)) {
+			num, mult = raw[:len(raw)-len(unit.suffix)], unit.mult
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || !(value >= 0) || math.IsInf(value, 0) || value*mult > math.MaxInt64/2 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	return int64(value * mult), nil
+}
+
+func parseBool(raw string, name string) (bool, error) {
+	if raw == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s", name)
+	}
+	return parsed, nil
+}
+
+const maxRedirectHops = 100
+
+// parseRedirect reads the redirect knobs for a /redirect/{n} path:
+// code (comma-separated 3xx codes cycled per hop), to (final target),
+// abs (absolute Location), scheme (absolute Location with this scheme),
+// loop (the last hop points back at the first) and start (chain length,
+// carried across hops).
+func parseRedirect(protocol Protocol, hops int, q url.Values) (*Redirect, error) {
+	if hops == 0 {
+		return nil, nil
+	}
+	if hops > maxRedirectHops {
+		return nil, fmt.Errorf("too many redirect hops")
+	}
+
+	codes := []int{http.StatusFound}
+	if raw := q.Get("code"); raw != "" {
+		codes = codes[:0]
+		for _, part := range strings.Split(raw, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || code < 300 || code > 399 {
+				return nil, fmt.Errorf("invalid redirect code")
+			}
+			codes = append(codes, code)
+		}
+	}
+
+	start := hops
+	if raw := q.Get("start"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < hops || parsed > maxRedirectHops {
+			return nil, fmt.Errorf("invalid redirect start")
+		}
+		start = parsed
+	}
+
+	absolute, err := parseBool(q.Get("abs"), "abs")
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := q.Get("scheme")
+	switch scheme {
+	case "":
+	case "http", "https":
+		absolute = true
+	default:
+		return nil, fmt.Errorf("invalid redirect scheme")
+	}
+
+	loop, err := parseBool(q.Get("loop"), "loop")
+	if err != nil {
+		return nil, err
+	}
+
+	to := q.Get("to")
+	if to == "" {
+		to = "/" + string(protocol) + "/status/200"
+	}
+
+	return &Redirect{
+		Hops:     hops,
+		Start:    start,
+		Codes:    codes,
+		To:       to,
+		Absolute: absolute,
+		Scheme:   scheme,
+		Loop:     loop,
+	}, nil
 }
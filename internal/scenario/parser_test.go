@@ -139,3 +139,571 @@ func TestParseRequestDefaultBurst(t *testing.T) {
 		t.Fatalf("burst = %v", got.RateLimit)
 	}
 }
+
+func TestParseRequestSequence(t *testing.T) {
+	u := &url.URL{Path: "/http/retry"}
+	q := u.Query()
+	q.Set("seq", "500x3,503:busy,200")
+	q.Set("seq_mode", "wrap")
+	u.RawQuery = q.Encode()
+
+	req := &http.Request{Method: http.MethodGet, URL: u}
+	got, err := ParseRequest(req)
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.Sequence == nil || !got.Sequence.Wrap {
+		t.Fatalf("sequence = %+v", got.Sequence)
+	}
+	want := []Step{{500, ""}, {500, ""}, {500, ""}, {503, "busy"}, {200, ""}}
+	if len(got.Sequence.Steps) != len(want) {
+		t.Fatalf("steps = %+v", got.Sequence.Steps)
+	}
+	for i, step := range want {
+		if got.Sequence.Steps[i] != step {
+			t.Fatalf("step %d = %+v, want %+v", i, got.Sequence.Steps[i], step)
+		}
+	}
+}
+
+func TestParseRequestInvalidSequence(t *testing.T) {
+	cases := []url.Values{
+		{"seq": {"abc"}},
+		{"seq": {"500x0"}},
+		{"seq": {"200"}, "seq_mode": {"loop"}},
+		{"seq_mode": {"wrap"}},
+	}
+	for _, q := range cases {
+		u := &url.URL{Path: "/http/retry", RawQuery: q.Encode()}
+		req := &http.Request{Method: http.MethodGet, URL: u}
+		if _, err := ParseRequest(req); err == nil {
+			t.Fatalf("expected error for %q", u.RawQuery)
+		}
+	}
+}
+
+func TestParseRequestFailure(t *testing.T) {
+	u := &url.URL{Path: "/http/flaky"}
+	q := u.Query()
+	q.Set("fail", "0.3")
+	q.Set("fail_status", "500:2,503")
+	q.Set("seed", "42")
+	u.RawQuery = q.Encode()
+
+	req := &http.Request{Method: http.MethodGet, URL: u}
+	got, err := ParseRequest(req)
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.Failure == nil || got.Failure.Probability != 0.3 {
+		t.Fatalf("failure = %+v", got.Failure)
+	}
+	want := []WeightedStatus{{500, 2}, {503, 1}}
+	if len(got.Failure.Statuses) != 2 || got.Failure.Statuses[0] != want[0] || got.Failure.Statuses[1] != want[1] {
+		t.Fatalf("statuses = %+v", got.Failure.Statuses)
+	}
+	if got.Seed == nil || *got.Seed != 42 {
+		t.Fatalf("seed = %v", got.Seed)
+	}
+}
+
+func TestParseRequestInvalidFailure(t *testing.T) {
+	cases := []url.Values{
+		{"fail": {"1.5"}},
+		{"fail": {"nope"}},
+		{"fail_status": {"500"}},
+		{"fail": {"0.1"}, "fail_status": {"500:0"}},
+		{"fail": {"1"}, "fail_status": {"500:9223372036854775807,503:1"}},
+		{"seed": {"abc"}},
+	}
+	for _, q := range cases {
+		u := &url.URL{Path: "/http/flaky", RawQuery: q.Encode()}
+		req := &http.Request{Method: http.MethodGet, URL: u}
+		if _, err := ParseRequest(req); err == nil {
+			t.Fatalf("expected error for %q", u.RawQuery)
+		}
+	}
+}
+
+func TestParseRequestDelayDistributions(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want Distribution
+	}{
+		{"100ms..500ms", Distribution{Kind: DistUniform, Min: 100 * time.Millisecond, Max: 500 * time.Millisecond}},
+		{"normal:200ms,50ms", Distribution{Kind: DistNormal, Mean: 200 * time.Millisecond, StdDev: 50 * time.Millisecond}},
+		{"lognormal:200ms,1s", Distribution{Kind: DistLogNormal, Mean: 200 * time.Millisecond, StdDev: time.Second}},
+	}
+	for _, tc := range cases {
+		u := &url.URL{Path: "/http/status/200", RawQuery: url.Values{"delay": {tc.raw}}.Encode()}
+		got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+		if err != nil {
+			t.Fatalf("%s: parse request: %v", tc.raw, err)
+		}
+		if got.Delay != 0 || got.DelayDist == nil {
+			t.Fatalf("%s: delay = %v, dist = %v", tc.raw, got.Delay, got.DelayDist)
+		}
+		d := *got.DelayDist
+		if d.Kind != tc.want.Kind || d.Min != tc.want.Min || d.Max != tc.want.Max || d.Mean != tc.want.Mean || d.StdDev != tc.want.StdDev {
+			t.Fatalf("%s: dist = %+v", tc.raw, d)
+		}
+	}
+}
+
+func TestParseRequestDelayPercentiles(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "delay=p99=2s,p50=50ms"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.DelayDist == nil || got.DelayDist.Kind != DistPercentile {
+		t.Fatalf("dist = %+v", got.DelayDist)
+	}
+	want := []Percentile{{50, 50 * time.Millisecond}, {99, 2 * time.Second}}
+	for i, p := range want {
+		if got.DelayDist.Percentiles[i] != p {
+			t.Fatalf("percentile %d = %+v", i, got.DelayDist.Percentiles[i])
+		}
+	}
+}
+
+func TestParseRequestInvalidDelayDistributions(t *testing.T) {
+	for _, raw := range []string{"500ms..100ms", "gamma:1s,1s", "normal:1s", "lognormal:0s,1s", "p50=2s,p99=1s", "p200=1s"} {
+		u := &url.URL{Path: "/http/status/200", RawQuery: url.Values{"delay": {raw}}.Encode()}
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseRequestFault(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "fault=reset"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.Fault != FaultReset {
+		t.Fatalf("fault = %q", got.Fault)
+	}
+
+	u = &url.URL{Path: "/http/status/200", RawQuery: "fault=explode"}
+	if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseRequestStream(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "chunks=4&chunk_delay=100ms"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.Stream == nil || got.Stream.Chunks != 4 || got.Stream.Delay != 100*time.Millisecond {
+		t.Fatalf("stream = %+v", got.Stream)
+	}
+
+	for _, raw := range []string{"chunks=0", "chunk_delay=1s", "chunks=2&chunk_delay=nope"} {
+		u := &url.URL{Path: "/http/status/200", RawQuery: raw}
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseRequestBandwidth(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "bw_down=1024&bw_up=512"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.BandwidthDown != 1024 || got.BandwidthUp != 512 {
+		t.Fatalf("bandwidth = %d/%d", got.BandwidthDown, got.BandwidthUp)
+	}
+
+	for _, raw := range []string{"bw_down=0", "bw_up=fast"} {
+		u := &url.URL{Path: "/http/status/200", RawQuery: raw}
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseRequestGenerator(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want Generator
+	}{
+		{"size=10MB", Generator{Kind: GenBytes, Size: 10 << 20, Fill: FillRepeat}},
+		{"size=512&fill=random", Generator{Kind: GenBytes, Size: 512, Fill: FillRandom}},
+		{"size=inf", Generator{Kind: GenBytes, Size: -1, Fill: FillRepeat}},
+		{"json_size=1.5KiB", Generator{Kind: GenJSON, Size: 1536}},
+	}
+	for _, tc := range cases {
+		u := &url.URL{Path: "/http/status/200", RawQuery: tc.raw}
+		got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+		if err != nil {
+			t.Fatalf("%s: parse request: %v", tc.raw, err)
+		}
+		if got.Generator == nil || *got.Generator != tc.want {
+			t.Fatalf("%s: generator = %+v", tc.raw, got.Generator)
+		}
+	}
+}
+
+func TestParseRequestInvalidGenerator(t *testing.T) {
+	for _, raw := range []string{"size=big", "size=1MB&json_size=1MB", "fill=random", "size=1&fill=zeros", "json_size=inf"} {
+		u := &url.URL{Path: "/http/status/200", RawQuery: raw}
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+
+	u := &url.URL{Path: "/jsonrpc/status/200", RawQuery: "size=inf"}
+	if _, err := ParseRequest(&http.Request{Method: http.MethodPost, URL: u}); err == nil {
+		t.Fatal("expected error for endless jsonrpc body")
+	}
+	u.RawQuery = "size=50GB"
+	if _, err := ParseRequest(&http.Request{Method: http.MethodPost, URL: u}); err == nil {
+		t.Fatal("expected error for huge jsonrpc body")
+	}
+	u.RawQuery = "json_size=1MB"
+	if _, err := ParseRequest(&http.Request{Method: http.MethodPost, URL: u}); err != nil {
+		t.Fatalf("jsonrpc json_size: %v", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{"0": 0, "100": 100, "100B": 100, "64KB": 64 << 10, "2M": 2 << 20, "1GiB": 1 << 30}
+	for raw, want := range cases {
+		got, err := ParseSize(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseSize(%q) = %d, %v", raw, got, err)
+		}
+	}
+}
+
+func TestParseRequestRedirect(t *testing.T) {
+	u := &url.URL{Path: "/http/redirect/5", RawQuery: "code=301,307&to=/http/status/204&abs=1&loop=1&start=6"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	rd := got.Redirect
+	if rd == nil || rd.Hops != 5 || rd.Start != 6 || !rd.Absolute || !rd.Loop || rd.To != "/http/status/204" {
+		t.Fatalf("redirect = %+v", rd)
+	}
+	if got.StatusCode != 307 {
+		t.Fatalf("status = %d", got.StatusCode)
+	}
+}
+
+func TestParseRequestRedirectDefaults(t *testing.T) {
+	u := &url.URL{Path: "/rest/redirect/2"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.Redirect == nil || got.Redirect.To != "/rest/status/200" || got.StatusCode != 302 {
+		t.Fatalf("redirect = %+v, status = %d", got.Redirect, got.StatusCode)
+	}
+}
+
+func TestParseRequestInvalidRedirect(t *testing.T) {
+	for _, raw := range []string{"code=200", "scheme=ftp", "start=1", "loop=maybe"} {
+		u := &url.URL{Path: "/http/redirect/2", RawQuery: raw}
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseRequestHeaders(t *testing.T) {
+	u := &url.URL{Path: "/rest/v1/users", RawQuery: "delay=1s"}
+	header := http.Header{}
+	header.Set("X-Rude-Status", "503")
+	header.Set("X-Rude-Delay", "5s")
+	header.Set("X-Rude-Fail-Status", "502")
+	header.Set("X-Rude-Fail", "1")
+	header.Add("X-Rude-H", "Retry-After:3")
+	header.Add("X-Rude-H", "X-Trace:abc")
+	header.Set("X-Rude-Chunk-Delay", "10ms")
+	header.Set("X-Rude-Chunks", "2")
+	req := &http.Request{Method: http.MethodGet, URL: u, Header: header}
+
+	got, err := ParseRequest(req)
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.StatusCode != 503 {
+		t.Fatalf("status = %d", got.StatusCode)
+	}
+	if got.Delay != time.Second {
+		t.Fatalf("delay = %v, query should win", got.Delay)
+	}
+	if got.Failure == nil || got.Failure.Statuses[0].StatusCode != 502 {
+		t.Fatalf("failure = %+v", got.Failure)
+	}
+	if got.Headers.Get("Retry-After") != "3" || got.Headers.Get("X-Trace") != "abc" {
+		t.Fatalf("headers = %v", got.Headers)
+	}
+	if got.Stream == nil || got.Stream.Chunks != 2 || got.Stream.Delay != 10*time.Millisecond {
+		t.Fatalf("stream = %+v", got.Stream)
+	}
+}
+
+func TestParseRequestPathStatusWinsOverHeader(t *testing.T) {
+	u := &url.URL{Path: "/http/status/201"}
+	req := &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{"X-Rude-Status": {"500"}}}
+
+	got, err := ParseRequest(req)
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.StatusCode != 201 {
+		t.Fatalf("status = %d", got.StatusCode)
+	}
+}
+
+func TestParseRequestInvalidHeaders(t *testing.T) {
+	for name, value := range map[string]string{
+		"X-Rude-Status": "999",
+		"X-Rude-Rl":     "fast",
+		"X-Rude-Fault":  "explode",
+	} {
+		u := &url.URL{Path: "/http/anything"}
+		req := &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{name: {value}}}
+		if _, err := ParseRequest(req); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseRequestSchedule(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "outage=10s..40s&flap=15s,5s&cron=*/5+9-17+*+*+1-5&cron_for=30s&sched_status=502&sched_key=global"}
+	req := &http.Request{Method: http.MethodGet, URL: u}
+
+	got, err := ParseRequest(req)
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	s := got.Schedule
+	if s == nil || s.Outage == nil || s.Outage.Start != 10*time.Second || s.Outage.End != 40*time.Second {
+		t.Fatalf("schedule = %+v", s)
+	}
+	if s.FlapUp != 15*time.Second || s.FlapDown != 5*time.Second || s.CronFor != 30*time.Second || s.Status != 502 || !s.Global {
+		t.Fatalf("schedule = %+v", s)
+	}
+
+	monday := time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC)
+	if !s.Cron.Match(monday) || s.Cron.Match(monday.Add(time.Minute)) || s.Cron.Match(monday.AddDate(0, 0, 5)) {
+		t.Fatal("cron match mismatch")
+	}
+}
+
+func TestParseRequestScheduleDefaults(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "outage=..30s&flap=1m"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	s := got.Schedule
+	if s.Outage.Start != 0 || s.Outage.End != 30*time.Second || s.FlapDown != time.Minute || s.Status != 503 || s.Global {
+		t.Fatalf("schedule = %+v", s)
+	}
+}
+
+func TestParseRequestInvalidSchedule(t *testing.T) {
+	cases := []string{
+		"outage=10s",
+		"outage=40s..10s",
+		"outage=-1s..",
+		"flap=0s",
+		"flap=1s,x",
+		"cron=*+*+*+*",
+		"cron=60+*+*+*+*",
+		"cron=*/0+*+*+*+*",
+		"cron=*+*+*+*+*&cron_for=48h",
+		"cron_for=1m",
+		"sched_status=503",
+		"flap=1s&sched_status=99",
+		"flap=1s&sched_key=client",
+	}
+	for _, q := range cases {
+		u := &url.URL{Path: "/http/status/200", RawQuery: q}
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
+
+func TestParseRequestCapacity(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "workers=4&queue=10&overload=429&contention=1.5"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	c := got.Capacity
+	if c == nil || c.Workers != 4 || c.Queue != 10 || c.Status != 429 || c.Shed || c.Contention != 1.5 {
+		t.Fatalf("capacity = %+v", c)
+	}
+
+	u.RawQuery = "workers=1&overload=shed"
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || !got.Capacity.Shed || got.Capacity.Queue != 0 {
+		t.Fatalf("capacity = %+v, err = %v", got.Capacity, err)
+	}
+
+	for _, q := range []string{"workers=0", "workers=x", "queue=3", "workers=1&queue=-1", "workers=1&overload=drop", "workers=1&overload=600", "workers=1&contention=-1", "workers=1&contention=NaN", "workers=1&contention=101", "workers=1&contention=1e308", "contention=1"} {
+		u.RawQuery = q
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
+
+func TestParseRequestConcurrency(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "conc=5"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.Concurrency == nil || got.Concurrency.Max != 5 || got.Concurrency.Status != 429 {
+		t.Fatalf("concurrency = %+v", got.Concurrency)
+	}
+
+	u.RawQuery = "conc=1&conc_status=503"
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.Concurrency.Status != 503 {
+		t.Fatalf("concurrency = %+v, err = %v", got.Concurrency, err)
+	}
+
+	for _, q := range []string{"conc=0", "conc=x", "conc_status=503", "conc=1&conc_status=42", "conc=1&conc_status=200"} {
+		u.RawQuery = q
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
+
+func TestParseRequestRateLimitAlgorithms(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "rl=100&rl_algo=fixed&rl_window=1m"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	rl := got.RateLimit
+	if rl.Algorithm != RateFixed || rl.Limit != 100 || rl.Window != time.Minute {
+		t.Fatalf("rate limit = %+v", rl)
+	}
+
+	u.RawQuery = "rl=5&rl_algo=log"
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.RateLimit.Window != time.Second || got.RateLimit.Algorithm != RateLog {
+		t.Fatalf("rate limit = %+v, err = %v", got.RateLimit, err)
+	}
+
+	u.RawQuery = "rl=2.5&rl_algo=token"
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.RateLimit.Algorithm != RateToken || got.RateLimit.Burst != 3 {
+		t.Fatalf("rate limit = %+v, err = %v", got.RateLimit, err)
+	}
+
+	for _, q := range []string{
+		"rl_algo=fixed",
+		"rl=1&rl_algo=leaky",
+		"rl=1&rl_window=1s",
+		"rl=2.5&rl_algo=sliding",
+		"rl=5&rl_algo=fixed&burst=2",
+		"rl=5&rl_algo=fixed&rl_window=0s",
+		"rl=1000000&rl_algo=log",
+	} {
+		u.RawQuery = q
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
+
+func TestParseRequestRateHeaders(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "rl=1"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.RateLimit.Headers != RateHeadersAll || got.RateLimit.RetryDate {
+		t.Fatalf("rate limit = %+v, err = %v", got.RateLimit, err)
+	}
+
+	u.RawQuery = "rl=1&rl_headers=ietf&rl_retry=date"
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.RateLimit.Headers != RateHeadersIETF || !got.RateLimit.RetryDate {
+		t.Fatalf("rate limit = %+v, err = %v", got.RateLimit, err)
+	}
+
+	for _, q := range []string{
+		"rl_headers=x",
+		"rl_retry=date",
+		"rl=1&rl_headers=draft",
+		"rl=1&rl_retry=minutes",
+	} {
+		u.RawQuery = q
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
+
+func TestParseRequestRateReject(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "rl=1"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.RateLimit.Reject.Status != http.StatusTooManyRequests {
+		t.Fatalf("rate limit = %+v, err = %v", got.RateLimit, err)
+	}
+
+	u.RawQuery = "rl=1&rl_status=503&rl_body=busy&rl_h=X-Throttled:1&rl_delay=250ms"
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	reject := got.RateLimit.Reject
+	if reject.Status != 503 || reject.Body != "busy" || reject.Headers.Get("X-Throttled") != "1" || reject.Delay != 250*time.Millisecond {
+		t.Fatalf("rate reject = %+v", reject)
+	}
+
+	for _, q := range []string{
+		"rl_status=503",
+		"rl_delay=1s",
+		"rl=1&rl_status=42",
+		"rl=1&rl_h=NoColon",
+		"rl=1&rl_delay=-1s",
+	} {
+		u.RawQuery = q
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
+
+func TestParseRequestRateKey(t *testing.T) {
+	u := &url.URL{Path: "/http/status/200", RawQuery: "rl=1&rl_key=header:x-api-key"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.RateKey != (RateKey{Kind: RateKeyHeader, Name: "X-Api-Key"}) {
+		t.Fatalf("rate key = %+v, err = %v", got.RateKey, err)
+	}
+
+	u.RawQuery = "conc=2&rl_key=bucket:orders"
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: u})
+	if err != nil || got.RateKey != (RateKey{Kind: RateKeyBucket, Name: "orders"}) {
+		t.Fatalf("rate key = %+v, err = %v", got.RateKey, err)
+	}
+
+	for _, q := range []string{
+		"rl_key=global",
+		"rl=1&rl_key=tenant",
+		"rl=1&rl_key=header:",
+		"rl=1&rl_key=bucket",
+		"rl=1&rl_key=path:x",
+	} {
+		u.RawQuery = q
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u}); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
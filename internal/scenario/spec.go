@@ -0,0 +1,336 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TokenPrefix starts paths carrying a scenario token: /s/{token}/any/path,
+// where the token is a base64url encoded JSON Spec.
+const TokenPrefix = "/s/"
+
+// Spec is the structured form of a scenario used where there is no smart
+// URL to parse, such as config file routes and path tokens. Params takes
+// any smart-URL query parameter by name (e.g. "fail", "size").
+type Spec struct {
+	Protocol  Protocol          `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Status    int               `json:"status,omitempty" yaml:"status,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body      string            `json:"body,omitempty" yaml:"body,omitempty"`
+	Delay     *DelaySpec        `json:"delay,omitempty" yaml:"delay,omitempty"`
+	RateLimit *RateLimitSpec    `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
+	Sequence  *SequenceSpec     `json:"sequence,omitempty" yaml:"sequence,omitempty"`
+	Stream    *StreamSpec       `json:"stream,omitempty" yaml:"stream,omitempty"`
+	Params    map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// RateLimitSpec mirrors rl, burst, rl_algo, rl_window, rl_key,
+// rl_headers and rl_retry. For the window algorithms RPS is the number of
+// requests allowed per Window.
+type RateLimitSpec struct {
+	RPS       float64         `json:"rps" yaml:"rps"`
+	Burst     int             `json:"burst,omitempty" yaml:"burst,omitempty"`
+	Algorithm string          `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	Window    string          `json:"window,omitempty" yaml:"window,omitempty"`
+	Key       string          `json:"key,omitempty" yaml:"key,omitempty"`
+	Headers   string          `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Retry     string          `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Reject    *RateRejectSpec `json:"reject,omitempty" yaml:"reject,omitempty"`
+}
+
+// RateRejectSpec mirrors rl_status, rl_body, rl_h and rl_delay.
+type RateRejectSpec struct {
+	Status  int               `json:"status,omitempty" yaml:"status,omitempty"`
+	Body    string            `json:"body,omitempty" yaml:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Delay   string            `json:"delay,omitempty" yaml:"delay,omitempty"`
+}
+
+// DelaySpec is a delay given either as a smart-URL delay string
+// ("200ms", "100ms..500ms", "normal:200ms,50ms") or as an object such as
+// {"dist": "normal", "mean": "200ms", "stddev": "50ms"}. Without Dist the
+// kind follows from the fields that are set.
+type DelaySpec struct {
+	Value       string            `json:"-" yaml:"-"`
+	Dist        DistributionKind  `json:"dist,omitempty" yaml:"dist,omitempty"`
+	Min         string            `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         string            `json:"max,omitempty" yaml:"max,omitempty"`
+	Mean        string            `json:"mean,omitempty" yaml:"mean,omitempty"`
+	StdDev      string            `json:"stddev,omitempty" yaml:"stddev,omitempty"`
+	Percentiles map[string]string `json:"percentiles,omitempty" yaml:"percentiles,omitempty"`
+}
+
+// SequenceSpec is the structured form of seq and seq_mode. Step bodies
+// may contain commas and colons.
+type SequenceSpec struct {
+	Steps []StepSpec `json:"steps" yaml:"steps"`
+	Mode  string     `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+type StepSpec struct {
+	Status int    `json:"status" yaml:"status"`
+	Body   string `json:"body,omitempty" yaml:"body,omitempty"`
+	Repeat int    `json:"repeat,omitempty" yaml:"repeat,omitempty"`
+}
+
+type StreamSpec struct {
+	Chunks     int    `json:"chunks" yaml:"chunks"`
+	ChunkDelay string `json:"chunk_delay,omitempty" yaml:"chunk_delay,omitempty"`
+}
+
+// FromSpec builds the scenario for a request with the given method and path.
+func FromSpec(spec Spec, method string, path string) (Scenario, error) {
+	protocol := spec.Protocol
+	switch protocol {
+	case "":
+		protocol = ProtocolHTTP
+	case ProtocolHTTP, ProtocolREST, ProtocolJSONRPC:
+	default:
+		return Scenario{}, ErrUnsupportedProtocol
+	}
+
+	status := spec.Status
+	if status == 0 {
+		status = 200
+	}
+	if status < 100 || status > 599 {
+		return Scenario{}, fmt.Errorf("invalid status")
+	}
+
+	sc, err := parseValues(protocol, method, path, status, 0, spec.Values())
+	if err != nil {
+		return Scenario{}, err
+	}
+	if spec.Sequence != nil {
+		if sc.Sequence, err = spec.Sequence.build(); err != nil {
+			return Scenario{}, err
+		}
+	}
+	return sc, nil
+}
+
+// Values flattens the spec into smart-URL query parameters. Dedicated
+// fields win over the same names in Params. Sequence is not flattened,
+// since its bodies need not survive the seq syntax.
+func (s Spec) Values() url.Values {
+	q := make(url.Values, len(s.Params)+4)
+	for name, value := range s.Params {
+		q.Set(name, value)
+	}
+	if s.Body != "" {
+		q.Set("body", s.Body)
+	}
+	if s.Delay != nil {
+		q.Set("delay", s.Delay.String())
+	}
+	if s.RateLimit != nil {
+		q.Set("rl", strconv.FormatFloat(s.RateLimit.RPS, 'f', -1, 64))
+		if s.RateLimit.Burst != 0 {
+			q.Set("burst", strconv.Itoa(s.RateLimit.Burst))
+		}
+		if s.RateLimit.Algorithm != "" {
+			q.Set("rl_algo", s.RateLimit.Algorithm)
+		}
+		if s.RateLimit.Window != "" {
+			q.Set("rl_window", s.RateLimit.Window)
+		}
+		if s.RateLimit.Key != "" {
+			q.Set("rl_key", s.RateLimit.Key)
+		}
+		if s.RateLimit.Headers != "" {
+			q.Set("rl_headers", s.RateLimit.Headers)
+		}
+		if s.RateLimit.Retry != "" {
+			q.Set("rl_retry", s.RateLimit.Retry)
+		}
+		if reject := s.RateLimit.Reject; reject != nil {
+			if reject.Status != 0 {
+				q.Set("rl_status", strconv.Itoa(reject.Status))
+			}
+			if reject.Body != "" {
+				q.Set("rl_body", reject.Body)
+			}
+			for name, value := range reject.Headers {
+				q.Add("rl_h", name+":"+value)
+			}
+			if reject.Delay != "" {
+				q.Set("rl_delay", reject.Delay)
+			}
+		}
+	}
+	if s.Sequence != nil {
+		q.Del("seq")
+		q.Del("seq_mode")
+	}
+	if s.Stream != nil {
+		q.Set("chunks", strconv.Itoa(s.Stream.Chunks))
+		if s.Stream.ChunkDelay != "" {
+			q.Set("chunk_delay", s.Stream.ChunkDelay)
+		}
+	}
+	for name, value := range s.Headers {
+		q.Add("h", name+":"+value)
+	}
+	return q
+}
+
+// String returns the delay in smart-URL syntax.
+func (d DelaySpec) String() string {
+	if d.Value != "" {
+		return d.Value
+	}
+
+	kind := d.Dist
+	if kind == "" {
+		switch {
+		case len(d.Percentiles) > 0:
+			kind = DistPercentile
+		case d.Min != "" || d.Max != "":
+			kind = DistUniform
+		case d.StdDev != "":
+			kind = DistNormal
+		default:
+			return d.Mean
+		}
+	}
+
+	switch kind {
+	case DistUniform:
+		return d.Min + ".." + d.Max
+	case DistNormal, DistLogNormal:
+		return string(kind) + ":" + d.Mean + "," + d.StdDev
+	case DistPercentile:
+		parts := make([]string, 0, len(d.Percentiles))
+		for rank, value := range d.Percentiles {
+			parts = append(parts, rank+"="+value)
+		}
+		slices.Sort(parts)
+		return strings.Join(parts, ",")
+	}
+	// Unknown kinds are left for parseDelay to reject.
+	return string(kind) + ":"
+}
+
+// UnmarshalJSON rejects unknown object fields, since a custom unmarshaler
+// does not inherit DisallowUnknownFields from the outer decoder.
+func (d *DelaySpec) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*d = DelaySpec{}
+		return json.Unmarshal(data, &d.Value)
+	}
+	type plain DelaySpec
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(d))
+}
+
+func (d DelaySpec) MarshalJSON() ([]byte, error) {
+	if d.Value != "" {
+		return json.Marshal(d.Value)
+	}
+	type plain DelaySpec
+	return json.Marshal(plain(d))
+}
+
+// UnmarshalYAML rejects unknown keys itself: node.Decode does not inherit
+// the outer decoder's KnownFields setting.
+func (d *DelaySpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*d = DelaySpec{}
+		return node.Decode(&d.Value)
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			if key := node.Content[i].Value; !delayFields[key] {
+				return fmt.Errorf("line %d: unknown delay field %q", node.Content[i].Line, key)
+			}
+		}
+	}
+	type plain DelaySpec
+	return node.Decode((*plain)(d))
+}
+
+// delayFields are the keys a delay object may have.
+var delayFields = map[string]bool{"dist": true, "min": true, "max": true, "mean": true, "stddev": true, "percentiles": true}
+
+func (d DelaySpec) MarshalYAML() (any, error) {
+	if d.Value != "" {
+		return d.Value, nil
+	}
+	type plain DelaySpec
+	return plain(d), nil
+}
+
+func (s *SequenceSpec) build() (*Sequence, error) {
+	wrap, err := parseSequenceMode(s.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("invalid seq")
+	}
+
+	var steps []Step
+	for _, step := range s.Steps {
+		if step.Status < 100 || step.Status > 599 {
+			return nil, fmt.Errorf("invalid seq status")
+		}
+		repeat := step.Repeat
+		if repeat == 0 {
+			repeat = 1
+		}
+		if repeat < 0 {
+			return nil, fmt.Errorf("invalid seq repeat")
+		}
+		if len(steps)+repeat > maxSequenceSteps {
+			return nil, fmt.Errorf("seq too long")
+		}
+		for i := 0; i < repeat; i++ {
+			steps = append(steps, Step{StatusCode: step.Status, Body: step.Body})
+		}
+	}
+	return &Sequence{Steps: steps, Wrap: wrap}, nil
+}
+
+// EncodeToken returns the path token for spec.
+func EncodeToken(spec Spec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeToken parses a path token. Padding is optional.
+func DecodeToken(token string) (Spec, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(token, "="))
+	if err != nil {
+		return Spec{}, fmt.Errorf("invalid token")
+	}
+	var spec Spec
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return Spec{}, fmt.Errorf("invalid token: %w", err)
+	}
+	return spec, nil
+}
+
+// parseToken serves /s/{token}/... paths. The token is the whole scenario;
+// query parameters and X-Rude-* headers are left to the client's own use.
+func parseToken(method string, path string) (Scenario, error) {
+	token, _, _ := strings.Cut(strings.TrimPrefix(path, TokenPrefix), "/")
+	spec, err := DecodeToken(token)
+	if err != nil {
+		return Scenario{}, err
+	}
+	return FromSpec(spec, method, path)
+}
@@ -0,0 +1,188 @@
+package scenario
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFromSpec(t *testing.T) {
+	spec := Spec{
+		Protocol:  ProtocolREST,
+		Status:    201,
+		Headers:   map[string]string{"Content-Type": "application/json"},
+		Body:      `{"ok":true}`,
+		Delay:     &DelaySpec{Value: "100ms..200ms"},
+		RateLimit: &RateLimitSpec{RPS: 5, Burst: 2, Reject: &RateRejectSpec{Status: 503, Delay: "1s"}},
+		Params:    map[string]string{"seq": "503,201", "body": "ignored"},
+	}
+
+	got, err := FromSpec(spec, http.MethodPost, "/v1/orders/7")
+	if err != nil {
+		t.Fatalf("from spec: %v", err)
+	}
+	if got.Protocol != ProtocolREST || got.Method != http.MethodPost || got.NormalizedPath != "/v1/orders/7" {
+		t.Fatalf("scenario = %+v", got)
+	}
+	if got.StatusCode != 201 || got.Body != `{"ok":true}` {
+		t.Fatalf("status = %d, body = %q", got.StatusCode, got.Body)
+	}
+	if got.Headers.Get("Content-Type") != "application/json" {
+		t.Fatalf("headers = %v", got.Headers)
+	}
+	if got.DelayDist == nil || got.DelayDist.Max != 200*time.Millisecond {
+		t.Fatalf("delay = %+v", got.DelayDist)
+	}
+	if got.RateLimit == nil || got.RateLimit.RPS != 5 || got.RateLimit.Burst != 2 {
+		t.Fatalf("rate limit = %+v", got.RateLimit)
+	}
+	if reject := got.RateLimit.Reject; reject.Status != 503 || reject.Delay != time.Second {
+		t.Fatalf("rate reject = %+v", reject)
+	}
+	if got.Sequence == nil || len(got.Sequence.Steps) != 2 {
+		t.Fatalf("sequence = %+v", got.Sequence)
+	}
+}
+
+func TestFromSpecDefaults(t *testing.T) {
+	got, err := FromSpec(Spec{}, http.MethodGet, "/health")
+	if err != nil {
+		t.Fatalf("from spec: %v", err)
+	}
+	if got.Protocol != ProtocolHTTP || got.StatusCode != 200 {
+		t.Fatalf("scenario = %+v", got)
+	}
+}
+
+func TestFromSpecInvalid(t *testing.T) {
+	cases := []Spec{
+		{Protocol: "grpc"},
+		{Status: 42},
+		{Delay: &DelaySpec{Value: "soon"}},
+		{Params: map[string]string{"fail": "2"}},
+	}
+	for _, spec := range cases {
+		if _, err := FromSpec(spec, http.MethodGet, "/"); err == nil {
+			t.Fatalf("expected error for %+v", spec)
+		}
+	}
+}
+
+func TestFromSpecStructured(t *testing.T) {
+	spec := Spec{
+		Sequence: &SequenceSpec{
+			Steps: []StepSpec{{Status: 503, Body: "busy, retry: later", Repeat: 2}, {Status: 200, Body: `{"a":1,"b":2}`}},
+			Mode:  "wrap",
+		},
+		Stream: &StreamSpec{Chunks: 4, ChunkDelay: "50ms"},
+		Params: map[string]string{"seq": "500"},
+	}
+
+	got, err := FromSpec(spec, http.MethodGet, "/")
+	if err != nil {
+		t.Fatalf("from spec: %v", err)
+	}
+	if got.Sequence == nil || len(got.Sequence.Steps) != 3 || !got.Sequence.Wrap {
+		t.Fatalf("sequence = %+v", got.Sequence)
+	}
+	if got.Sequence.Steps[1].Body != "busy, retry: later" || got.Sequence.Steps[2].Body != `{"a":1,"b":2}` {
+		t.Fatalf("steps = %+v", got.Sequence.Steps)
+	}
+	if got.Stream == nil || got.Stream.Chunks != 4 || got.Stream.Delay != 50*time.Millisecond {
+		t.Fatalf("stream = %+v", got.Stream)
+	}
+
+	for _, bad := range []*SequenceSpec{{}, {Steps: []StepSpec{{Status: 42}}}, {Steps: []StepSpec{{Status: 200, Repeat: -1}}}, {Steps: []StepSpec{{Status: 200}}, Mode: "loop"}} {
+		if _, err := FromSpec(Spec{Sequence: bad}, http.MethodGet, "/"); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestDelaySpec(t *testing.T) {
+	cases := map[string]string{
+		`"250ms"`:                                           "250ms",
+		`{"min":"10ms","max":"20ms"}`:                       "10ms..20ms",
+		`{"mean":"200ms","stddev":"50ms"}`:                  "normal:200ms,50ms",
+		`{"dist":"lognormal","mean":"200ms","stddev":"1s"}`: "lognormal:200ms,1s",
+		`{"percentiles":{"p99":"2s","p50":"50ms"}}`:         "p50=50ms,p99=2s",
+		`{"mean":"1s"}`:                                     "1s",
+	}
+	for raw, want := range cases {
+		var d DelaySpec
+		if err := json.Unmarshal([]byte(raw), &d); err != nil {
+			t.Fatalf("%s: %v", raw, err)
+		}
+		if d.String() != want {
+			t.Fatalf("%s: delay = %q, want %q", raw, d.String(), want)
+		}
+		if _, err := FromSpec(Spec{Delay: &d}, http.MethodGet, "/"); err != nil {
+			t.Fatalf("%s: from spec: %v", raw, err)
+		}
+	}
+
+	var spec Spec
+	if err := yaml.Unmarshal([]byte("delay: {min: 1s, max: 2s}"), &spec); err != nil || spec.Delay.String() != "1s..2s" {
+		t.Fatalf("yaml object = %+v, %v", spec.Delay, err)
+	}
+	if err := yaml.Unmarshal([]byte("delay: 3s"), &spec); err != nil || spec.Delay.String() != "3s" {
+		t.Fatalf("yaml string = %+v, %v", spec.Delay, err)
+	}
+	if err := yaml.Unmarshal([]byte("delay: {min: 1s, maks: 2s}"), &spec); err == nil {
+		t.Fatal("expected error for unknown yaml delay field")
+	}
+
+	if _, err := FromSpec(Spec{Delay: &DelaySpec{Dist: "pareto", Mean: "1s"}}, http.MethodGet, "/"); err == nil {
+		t.Fatal("expected error for unknown distribution")
+	}
+}
+
+func TestParseRequestToken(t *testing.T) {
+	token, err := EncodeToken(Spec{
+		Protocol: ProtocolREST,
+		Status:   429,
+		Delay:    &DelaySpec{Min: "1ms", Max: "2ms"},
+		Sequence: &SequenceSpec{Steps: []StepSpec{{Status: 503}, {Status: 200, Body: "a,b"}}},
+	})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	path := "/s/" + token + "/v1/users/7"
+	u := &url.URL{Path: path, RawQuery: "status=500&delay=9s"}
+	got, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{"X-Rude-Status": {"500"}}})
+	if err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if got.Protocol != ProtocolREST || got.StatusCode != 429 || got.NormalizedPath != path {
+		t.Fatalf("scenario = %+v", got)
+	}
+	if got.DelayDist == nil || got.DelayDist.Max != 2*time.Millisecond {
+		t.Fatalf("delay = %+v", got.DelayDist)
+	}
+	if got.Sequence == nil || got.Sequence.Steps[1].Body != "a,b" {
+		t.Fatalf("sequence = %+v", got.Sequence)
+	}
+
+	padded := base64.URLEncoding.EncodeToString([]byte(`{"status":204}`))
+	got, err = ParseRequest(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/s/" + padded}})
+	if err != nil || got.StatusCode != 204 {
+		t.Fatalf("padded token: %+v, %v", got, err)
+	}
+
+	for _, bad := range []string{
+		"/s/!!!/x",
+		"/s/" + base64.RawURLEncoding.EncodeToString([]byte(`{"stauts":200}`)),
+		"/s/" + base64.RawURLEncoding.EncodeToString([]byte(`{"delay":{"mena":"1s"}}`)),
+		"/s/",
+	} {
+		if _, err := ParseRequest(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: bad}}); err == nil {
+			t.Fatalf("%s: expected error", bad)
+		}
+	}
+}
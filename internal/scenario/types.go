@@ -13,9 +13,81 @@ const (
 	ProtocolJSONRPC Protocol = "jsonrpc"
 )
 
+type Fault string
+
+const (
+	FaultReset    Fault = "reset"
+	FaultClose    Fault = "close"
+	FaultHang     Fault = "hang"
+	FaultHeaders  Fault = "headers"
+	FaultTruncate Fault = "truncate"
+)
+
+type RateAlgorithm string
+
+const (
+	RateToken   RateAlgorithm = "token"
+	RateFixed   RateAlgorithm = "fixed"
+	RateSliding RateAlgorithm = "sliding"
+	RateLog     RateAlgorithm = "log"
+)
+
+// RateHeaders selects which rate-limit headers responses carry.
+type RateHeaders string
+
+const (
+	RateHeadersAll  RateHeaders = "all"
+	RateHeadersX    RateHeaders = "x"
+	RateHeadersIETF RateHeaders = "ietf"
+	RateHeadersNone RateHeaders = "none"
+)
+
+// RateLimit is a token bucket refilling at RPS with room for Burst, or,
+// for the window algorithms, Limit requests per Window. RetryDate sends
+// Retry-After as an HTTP-date instead of seconds.
 type RateLimit struct {
-	RPS   float64
-	Burst int
+	Algorithm RateAlgorithm
+	RPS       float64
+	Burst     int
+	Limit     int
+	Window    time.Duration
+	Headers   RateHeaders
+	RetryDate bool
+	Reject    RateReject
+}
+
+// RateReject is the response to rate-limited requests, sent after Delay.
+// An empty Body keeps the protocol's default message.
+type RateReject struct {
+	Status  int
+	Body    string
+	Headers http.Header
+	Delay   time.Duration
+}
+
+// RateKeyKind selects what rate and concurrency limits are counted per.
+type RateKeyKind string
+
+const (
+	RateKeyIP     RateKeyKind = "ip"
+	RateKeyPath   RateKeyKind = "path"
+	RateKeyGlobal RateKeyKind = "global"
+	RateKeyHeader RateKeyKind = "header"
+	RateKeyBucket RateKeyKind = "bucket"
+)
+
+// RateKey is rl_key. Name is the header for RateKeyHeader and the bucket
+// for RateKeyBucket. The zero value keys by client IP.
+type RateKey struct {
+	Kind RateKeyKind
+	Name string
+}
+
+// Concurrency caps the requests in flight per rate-limit key; requests
+// over Max are rejected with Status.
+type Concurrency struct {
+	Max    int
+	Status int
 }
 
 type Scenario struct {
@@ -24,7 +96,141 @@ type Scenario struct {
 	NormalizedPath string
 	StatusCode     int
 	Delay          time.Duration
+	DelayDist      *Distribution
 	RateLimit      *RateLimit
+	Concurrency    *Concurrency
+	RateKey        RateKey
 	Headers        http.Header
 	Body           string
+	Sequence       *Sequence
+	Failure        *Failure
+	Seed           *int64
+	Fault          Fault
+	Stream         *Stream
+	BandwidthDown  int64
+	BandwidthUp    int64
+	Generator      *Generator
+	Template       bool
+	Redirect       *Redirect
+	Schedule       *Schedule
+	Capacity       *Capacity
+	PathParams     map[string]string
+}
+
+type Step struct {
+	StatusCode int
+	Body       string
+}
+
+type Sequence struct {
+	Steps []Step
+	Wrap  bool
+}
+
+type WeightedStatus struct {
+	StatusCode int
+	Weight     int
+}
+
+type Failure struct {
+	Probability float64
+	Statuses    []WeightedStatus
+}
+
+type DistributionKind string
+
+const (
+	DistUniform    DistributionKind = "uniform"
+	DistNormal     DistributionKind = "normal"
+	DistLogNormal  DistributionKind = "lognormal"
+	DistPercentile DistributionKind = "percentile"
+)
+
+type Percentile struct {
+	Rank  float64
+	Value time.Duration
+}
+
+// Distribution describes a random delay. Uniform uses Min/Max, normal and
+// log-normal use Mean/StdDev, percentile uses Percentiles sorted by rank.
+type Distribution struct {
+	Kind        DistributionKind
+	Min         time.Duration
+	Max         time.Duration
+	Mean        time.Duration
+	StdDev      time.Duration
+	Percentiles []Percentile
+}
+
+// Stream splits the response body into Chunks flushed writes separated by Delay.
+type Stream struct {
+	Chunks int
+	Delay  time.Duration
+}
+
+type GeneratorKind string
+
+const (
+	GenBytes GeneratorKind = "bytes"
+	GenJSON  GeneratorKind = "json"
+)
+
+type Fill string
+
+const (
+	FillRepeat Fill = "repeat"
+	FillRandom Fill = "random"
+)
+
+// Generator replaces the literal body with generated content. Size is in
+// bytes; a negative size means an endless stream.
+type Generator struct {
+	Kind GeneratorKind
+	Size int64
+	Fill Fill
+}
+
+// Redirect describes one hop of a redirect chain. Hops counts the hops left
+// including this one; Start is the chain length a loop returns to.
+type Redirect struct {
+	Hops     int
+	Start    int
+	Codes    []int
+	To       string
+	Absolute bool
+	Scheme   string
+	Loop     bool
+}
+
+// Schedule fails a scenario with Status during time windows: an outage
+// relative to the first request, flapping between healthy and unhealthy
+// phases, and cron windows. Windows are tracked per protocol and path,
+// or across all scheduled scenarios when Global is set.
+type Schedule struct {
+	Outage   *Window
+	FlapUp   time.Duration
+	FlapDown time.Duration
+	Cron     *Cron
+	CronFor  time.Duration
+	Status   int
+	Global   bool
+}
+
+// Window is a span relative to the first request. End is zero when the
+// window never closes.
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Capacity simulates a backend with Workers concurrent workers and room
+// for Queue waiting requests; the scenario's delay is the service time.
+// Requests beyond the queue get Status, or lose their connection when
+// Shed is set. Contention stretches the service time as workers fill up.
+type Capacity struct {
+	Workers    int
+	Queue      int
+	Status     int
+	Shed       bool
+	Contention float64
 }
@@ -0,0 +1,98 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+// Store remembers when each schedule key saw its first request.
+type Store struct {
+	mu      sync.Mutex
+	started map[string]time.Time
+	used    map[string]time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		started: make(map[string]time.Time),
+		used:    make(map[string]time.Time),
+	}
+}
+
+// Key identifies the clock a scenario's schedule runs on: its protocol
+// and path, or a single clock for all global schedules.
+func Key(sc scenario.Scenario) string {
+	if sc.Schedule != nil && sc.Schedule.Global {
+		return "global"
+	}
+	return string(sc.Protocol) + "|" + sc.NormalizedPath
+}
+
+// Down reports whether the scenario is inside one of its failure windows
+// at now. The first call for a key starts its clock.
+func Down(store *Store, sc scenario.Scenario, now time.Time) bool {
+	s := sc.Schedule
+	if s == nil {
+		return false
+	}
+	if s.Cron != nil && cronDown(s.Cron, s.CronFor, now) {
+		return true
+	}
+	if s.Outage == nil && s.FlapUp == 0 {
+		return false
+	}
+
+	elapsed := now.Sub(store.start(Key(sc), now))
+	if o := s.Outage; o != nil && elapsed >= o.Start && (o.End == 0 || elapsed < o.End) {
+		return true
+	}
+	if s.FlapUp > 0 && elapsed%(s.FlapUp+s.FlapDown) >= s.FlapUp {
+		return true
+	}
+	return false
+}
+
+// cronDown reports whether a matching minute started within window before now.
+func cronDown(cron *scenario.Cron, window time.Duration, now time.Time) bool {
+	for m := now.Truncate(time.Minute); now.Sub(m) < window; m = m.Add(-time.Minute) {
+		if cron.Match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) start(key string, now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.used[key] = now
+	if t, ok := s.started[key]; ok {
+		return t
+	}
+	s.started[key] = now
+	return now
+}
+
+// Sweep forgets keys unused for longer than ttl, so their clocks restart
+// on the next request.
+func (s *Store) Sweep(now time.Time, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, used := range s.used {
+		if now.Sub(used) > ttl {
+			delete(s.started, key)
+			delete(s.used, key)
+		}
+	}
+}
+
+// Reset forgets every first request, so outage and flap clocks restart.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.started)
+	clear(s.used)
+}
@@ -0,0 +1,107 @@
+package schedule
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+func scheduled(path string, s scenario.Schedule) scenario.Scenario {
+	return scenario.Scenario{Protocol: scenario.ProtocolHTTP, NormalizedPath: path, Schedule: &s}
+}
+
+func TestDownOutage(t *testing.T) {
+	store := NewStore()
+	sc := scheduled("/a", scenario.Schedule{Outage: &scenario.Window{Start: 10 * time.Second, End: 40 * time.Second}})
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := map[time.Duration]bool{0: false, 9 * time.Second: false, 10 * time.Second: true, 39 * time.Second: true, 40 * time.Second: false}
+	Down(store, sc, t0)
+	for offset, want := range cases {
+		if got := Down(store, sc, t0.Add(offset)); got != want {
+			t.Fatalf("T+%v down = %v, want %v", offset, got, want)
+		}
+	}
+
+	open := scheduled("/b", scenario.Schedule{Outage: &scenario.Window{Start: time.Second}})
+	if Down(store, open, t0) || !Down(store, open, t0.Add(time.Hour)) {
+		t.Fatal("open-ended outage mismatch")
+	}
+}
+
+func TestDownFlap(t *testing.T) {
+	store := NewStore()
+	sc := scheduled("/a", scenario.Schedule{FlapUp: 15 * time.Second, FlapDown: 5 * time.Second})
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	Down(store, sc, t0)
+	cases := map[time.Duration]bool{14 * time.Second: false, 15 * time.Second: true, 19 * time.Second: true, 20 * time.Second: false, 36 * time.Second: true}
+	for offset, want := range cases {
+		if got := Down(store, sc, t0.Add(offset)); got != want {
+			t.Fatalf("T+%v down = %v, want %v", offset, got, want)
+		}
+	}
+}
+
+func TestDownKeys(t *testing.T) {
+	store := NewStore()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	outage := scenario.Schedule{Outage: &scenario.Window{End: 10 * time.Second}}
+
+	a := scheduled("/a", outage)
+	b := scheduled("/b", outage)
+	Down(store, a, t0)
+	if !Down(store, b, t0.Add(time.Minute)) {
+		t.Fatal("routes should have separate clocks")
+	}
+
+	outage.Global = true
+	ga, gb := scheduled("/a", outage), scheduled("/b", outage)
+	Down(store, ga, t0)
+	if Down(store, gb, t0.Add(time.Minute)) {
+		t.Fatal("global schedules should share a clock")
+	}
+	if Key(ga) != Key(gb) || Key(a) == Key(b) {
+		t.Fatalf("keys = %q %q %q %q", Key(ga), Key(gb), Key(a), Key(b))
+	}
+}
+
+func TestSweepRestartsIdleClocks(t *testing.T) {
+	store := NewStore()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sc := scheduled("/a", scenario.Schedule{Outage: &scenario.Window{End: 10 * time.Second}})
+
+	Down(store, sc, t0)
+	store.Sweep(t0.Add(30*time.Minute), time.Hour)
+	if Down(store, sc, t0.Add(time.Hour)) {
+		t.Fatal("recently used clock should keep running")
+	}
+	store.Sweep(t0.Add(3*time.Hour), time.Hour)
+	if !Down(store, sc, t0.Add(3*time.Hour)) {
+		t.Fatal("idle clock should restart")
+	}
+}
+
+func TestDownCron(t *testing.T) {
+	sc, err := scenario.ParseRequest(mustRequest(t, "/http/status/200?cron=0+*+*+*+*&cron_for=90s"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	store := NewStore()
+	hour := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[time.Duration]bool{-time.Second: false, 0: true, 89 * time.Second: true, 90 * time.Second: false}
+	for offset, want := range cases {
+		if got := Down(store, sc, hour.Add(offset)); got != want {
+			t.Fatalf("%v down = %v, want %v", hour.Add(offset), got, want)
+		}
+	}
+}
+
+func mustRequest(t *testing.T, target string) *http.Request {
+	t.Helper()
+	return httptest.NewRequest(http.MethodGet, target, nil)
+}
@@ -0,0 +1,90 @@
+package sequence
+
+import (
+	"sync"
+	"time"
+
+	"rudeserver/internal/ratelimit"
+	"rudeserver/internal/scenario"
+)
+
+// Store keeps a call cursor per limiter-style key.
+type Store struct {
+	mu      sync.Mutex
+	cursors map[string]int
+	counts  map[string]int
+	used    map[string]time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		cursors: make(map[string]int),
+		counts:  make(map[string]int),
+		used:    make(map[string]time.Time),
+	}
+}
+
+// Next returns the step for the current call and advances the key's cursor.
+// Past the last step the cursor either wraps or sticks on the last step.
+func Next(store *Store, sc scenario.Scenario, clientIP string) (scenario.Step, bool) {
+	if sc.Sequence == nil || len(sc.Sequence.Steps) == 0 {
+		return scenario.Step{}, false
+	}
+
+	steps := sc.Sequence.Steps
+	n := store.advance(ratelimit.Key(sc, clientIP))
+	if n >= len(steps) {
+		if sc.Sequence.Wrap {
+			n %= len(steps)
+		} else {
+			n = len(steps) - 1
+		}
+	}
+	return steps[n], true
+}
+
+// Count returns the 1-based call number for the scenario's key. It is kept
+// apart from sequence cursors so templates do not disturb sequences.
+func Count(store *Store, sc scenario.Scenario, clientIP string) int {
+	key := ratelimit.Key(sc, clientIP)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.counts[key]++
+	store.used[key] = time.Now()
+	return store.counts[key]
+}
+
+func (s *Store) advance(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.cursors[key]
+	s.cursors[key] = n + 1
+	s.used[key] = time.Now()
+	return n
+}
+
+// Sweep forgets the cursors and counts of keys unused for longer than ttl,
+// so their next call starts over.
+func (s *Store) Sweep(now time.Time, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, used := range s.used {
+		if now.Sub(used) > ttl {
+			delete(s.cursors, key)
+			delete(s.counts, key)
+			delete(s.used, key)
+		}
+	}
+}
+
+// Reset rewinds every sequence and call count.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.cursors)
+	clear(s.counts)
+	clear(s.used)
+}
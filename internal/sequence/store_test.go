@@ -0,0 +1,113 @@
+package sequence
+
+import (
+	"testing"
+	"time"
+
+	"rudeserver/internal/scenario"
+)
+
+func seqScenario(wrap bool, codes ...int) scenario.Scenario {
+	steps := make([]scenario.Step, 0, len(codes))
+	for _, code := range codes {
+		steps = append(steps, scenario.Step{StatusCode: code})
+	}
+	return scenario.Scenario{
+		Protocol:       scenario.ProtocolHTTP,
+		Method:         "GET",
+		NormalizedPath: "/anything",
+		Sequence:       &scenario.Sequence{Steps: steps, Wrap: wrap},
+	}
+}
+
+func TestNextSticksOnLast(t *testing.T) {
+	store := NewStore()
+	sc := seqScenario(false, 503, 503, 200)
+
+	want := []int{503, 503, 200, 200, 200}
+	for i, code := range want {
+		step, ok := Next(store, sc, "203.0.113.1")
+		if !ok {
+			t.Fatalf("call %d: expected step", i)
+		}
+		if step.StatusCode != code {
+			t.Fatalf("call %d: status = %d, want %d", i, step.StatusCode, code)
+		}
+	}
+}
+
+func TestNextWraps(t *testing.T) {
+	store := NewStore()
+	sc := seqScenario(true, 500, 200)
+
+	want := []int{500, 200, 500, 200}
+	for i, code := range want {
+		step, _ := Next(store, sc, "203.0.113.1")
+		if step.StatusCode != code {
+			t.Fatalf("call %d: status = %d, want %d", i, step.StatusCode, code)
+		}
+	}
+}
+
+func TestNextIsPerKey(t *testing.T) {
+	store := NewStore()
+	sc := seqScenario(false, 503, 200)
+
+	if step, _ := Next(store, sc, "203.0.113.1"); step.StatusCode != 503 {
+		t.Fatalf("first client status = %d", step.StatusCode)
+	}
+	if step, _ := Next(store, sc, "203.0.113.2"); step.StatusCode != 503 {
+		t.Fatalf("second client status = %d", step.StatusCode)
+	}
+}
+
+func TestNextWithoutSequence(t *testing.T) {
+	store := NewStore()
+	if _, ok := Next(store, scenario.Scenario{}, "203.0.113.1"); ok {
+		t.Fatal("expected no step")
+	}
+}
+
+func TestCountIsIndependentOfCursor(t *testing.T) {
+	store := NewStore()
+	sc := seqScenario(false, 503, 200)
+
+	if n := Count(store, sc, "203.0.113.1"); n != 1 {
+		t.Fatalf("count = %d", n)
+	}
+	if n := Count(store, sc, "203.0.113.1"); n != 2 {
+		t.Fatalf("count = %d", n)
+	}
+	if step, _ := Next(store, sc, "203.0.113.1"); step.StatusCode != 503 {
+		t.Fatalf("status = %d", step.StatusCode)
+	}
+}
+
+func TestSweepForgetsIdleKeys(t *testing.T) {
+	store := NewStore()
+	sc := seqScenario(false, 503, 200)
+	Next(store, sc, "203.0.113.1")
+
+	store.Sweep(time.Now(), time.Hour)
+	if step, _ := Next(store, sc, "203.0.113.1"); step.StatusCode != 200 {
+		t.Fatalf("recent key should keep its cursor, got %d", step.StatusCode)
+	}
+	store.Sweep(time.Now().Add(2*time.Hour), time.Hour)
+	if step, _ := Next(store, sc, "203.0.113.1"); step.StatusCode != 503 {
+		t.Fatalf("idle key should start over, got %d", step.StatusCode)
+	}
+}
+
+func TestSweepForgetsIdleCounts(t *testing.T) {
+	store := NewStore()
+	sc := seqScenario(false, 200)
+	Count(store, sc, "203.0.113.1")
+
+	store.Sweep(time.Now().Add(2*time.Hour), time.Hour)
+	if n := Count(store, sc, "203.0.113.1"); n != 1 {
+		t.Fatalf("idle count should start over, got %d", n)
+	}
+	if len(store.counts) != 1 || len(store.used) != 1 {
+		t.Fatalf("counts = %v, used = %v", store.counts, store.used)
+	}
+}
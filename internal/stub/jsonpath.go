@@ -0,0 +1,152 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonStep is one step of a JSONPath expression. The supported subset is
+// $, .name, ['name'], [N], [*], .* and ..name.
+type jsonStep struct {
+	name      string
+	index     int
+	wildcard  bool
+	recursive bool
+	isIndex   bool
+}
+
+func parseJSONPath(expr string) ([]jsonStep, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(expr), "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid json_path %q: must start with $", expr)
+	}
+
+	steps := []jsonStep{}
+	for rest != "" {
+		var step jsonStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			name, tail := cutName(rest)
+			if name == "" {
+				return nil, fmt.Errorf("invalid json_path %q", expr)
+			}
+			step.name, step.wildcard = name, name == "*"
+			rest = tail
+		case rest[0] == '.':
+			name, tail := cutName(rest[1:])
+			if name == "" {
+				return nil, fmt.Errorf("invalid json_path %q", expr)
+			}
+			step.name, step.wildcard = name, name == "*"
+			rest = tail
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json_path %q", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				step.wildcard = true
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				step.name = inner[1 : len(inner)-1]
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid json_path %q", expr)
+				}
+				step.index, step.isIndex = n, true
+			}
+		default:
+			return nil, fmt.Errorf("invalid json_path %q", expr)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func cutName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// evalJSONPath returns the selected values as strings: JSON strings as
+// their contents, everything else in its JSON encoding.
+func evalJSONPath(steps []jsonStep, doc any) []string {
+	nodes := []any{doc}
+	for _, step := range steps {
+		var next []any
+		for _, node := range nodes {
+			if step.recursive {
+				next = append(next, descend(step, node)...)
+			} else {
+				next = append(next, selectChild(step, node)...)
+			}
+		}
+		nodes = next
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if s, ok := node.(string); ok {
+			values = append(values, s)
+			continue
+		}
+		b, _ := json.Marshal(node)
+		values = append(values, string(b))
+	}
+	return values
+}
+
+func selectChild(step jsonStep, node any) []any {
+	switch v := node.(type) {
+	case map[string]any:
+		if step.wildcard {
+			out := make([]any, 0, len(v))
+			for _, child := range v {
+				out = append(out, child)
+			}
+			return out
+		}
+		if child, ok := v[step.name]; ok && !step.isIndex {
+			return []any{child}
+		}
+	case []any:
+		if step.wildcard {
+			return v
+		}
+		if step.isIndex {
+			i := step.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				return []any{v[i]}
+			}
+		}
+	}
+	return nil
+}
+
+func descend(step jsonStep, node any) []any {
+	out := selectChild(step, node)
+	switch v := node.(type) {
+	case map[string]any:
+		for _, child := range v {
+			out = append(out, descend(step, child)...)
+		}
+	case []any:
+		for _, child := range v {
+			out = append(out, descend(step, child)...)
+		}
+	}
+	return out
+}
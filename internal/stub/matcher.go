@@ -0,0 +1,261 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Request is the part of an HTTP request that matchers look at. It is
+// built from live requests and from request log entries alike.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+func NewRequest(r *http.Request, body []byte) Request {
+	return Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header,
+		Body:   body,
+	}
+}
+
+func (r Request) cookies(name string) []string {
+	var values []string
+	for _, c := range (&http.Request{Header: r.Header}).Cookies() {
+		if c.Name == name {
+			values = append(values, c.Value)
+		}
+	}
+	return values
+}
+
+// Predicate tests a string value. All set fields must hold; Matches is a
+// regular expression that must match the whole value.
+type Predicate struct {
+	EqualTo  *string `json:"equal_to,omitempty" yaml:"equal_to,omitempty"`
+	Contains string  `json:"contains,omitempty" yaml:"contains,omitempty"`
+	Matches  string  `json:"matches,omitempty" yaml:"matches,omitempty"`
+	Absent   bool    `json:"absent,omitempty" yaml:"absent,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// BodyPattern applies a predicate to the whole body, or to the values
+// selected by a JSONPath or XPath expression. A path without a predicate
+// only requires the path to select something.
+type BodyPattern struct {
+	Predicate `yaml:",inline"`
+	JSONPath  string `json:"json_path,omitempty" yaml:"json_path,omitempty"`
+	XPath     string `json:"xpath,omitempty" yaml:"xpath,omitempty"`
+
+	jsonPath []jsonStep
+	xPath    []xmlStep
+}
+
+// Matcher selects requests. Empty fields match anything.
+type Matcher struct {
+	Method      string               `json:"method,omitempty" yaml:"method,omitempty"`
+	Path        string               `json:"path,omitempty" yaml:"path,omitempty"`
+	PathPattern string               `json:"path_pattern,omitempty" yaml:"path_pattern,omitempty"`
+	Query       map[string]Predicate `json:"query,omitempty" yaml:"query,omitempty"`
+	Headers     map[string]Predicate `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Cookies     map[string]Predicate `json:"cookies,omitempty" yaml:"cookies,omitempty"`
+	Body        []BodyPattern        `json:"body,omitempty" yaml:"body,omitempty"`
+
+	pathPattern *regexp.Regexp
+}
+
+// Compile validates the matcher and prepares its expressions. It must be
+// called before Match.
+func (m *Matcher) Compile() error {
+	m.Method = strings.ToUpper(m.Method)
+	if m.Path != "" && m.PathPattern != "" {
+		return fmt.Errorf("path and path_pattern are mutually exclusive")
+	}
+	if m.PathPattern != "" {
+		re, err := compileFull(m.PathPattern)
+		if err != nil {
+			return fmt.Errorf("invalid path_pattern: %w", err)
+		}
+		m.pathPattern = re
+	}
+
+	for _, group := range []struct {
+		name  string
+		preds map[string]Predicate
+	}{{"query", m.Query}, {"headers", m.Headers}, {"cookies", m.Cookies}} {
+		for key, pred := range group.preds {
+			if err := pred.compile(); err != nil {
+				return fmt.Errorf("%s %s: %w", group.name, key, err)
+			}
+			group.preds[key] = pred
+		}
+	}
+
+	for i := range m.Body {
+		if err := m.Body[i].compile(); err != nil {
+			return fmt.Errorf("body %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether req satisfies the matcher. Named groups in
+// path_pattern are returned as path parameters.
+func (m *Matcher) Match(req Request) (map[string]string, bool) {
+	if m.Method != "" && m.Method != "ANY" && m.Method != req.Method {
+		return nil, false
+	}
+
+	var params map[string]string
+	switch {
+	case m.Path != "":
+		if m.Path != req.Path {
+			return nil, false
+		}
+	case m.pathPattern != nil:
+		groups := m.pathPattern.FindStringSubmatch(req.Path)
+		if groups == nil {
+			return nil, false
+		}
+		for i, name := range m.pathPattern.SubexpNames() {
+			if name == "" {
+				continue
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[name] = groups[i]
+		}
+	}
+
+	for name, pred := range m.Query {
+		if !pred.test(req.Query[name]) {
+			return nil, false
+		}
+	}
+	for name, pred := range m.Headers {
+		if !pred.test(req.Header.Values(name)) {
+			return nil, false
+		}
+	}
+	for name, pred := range m.Cookies {
+		if !pred.test(req.cookies(name)) {
+			return nil, false
+		}
+	}
+	for i := range m.Body {
+		if !m.Body[i].test(req.Body) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (m *Matcher) needsBody() bool {
+	return len(m.Body) > 0
+}
+
+func (p *Predicate) compile() error {
+	if p.Absent && (p.EqualTo != nil || p.Contains != "" || p.Matches != "") {
+		return fmt.Errorf("absent cannot be combined with other predicates")
+	}
+	if p.Matches != "" {
+		re, err := compileFull(p.Matches)
+		if err != nil {
+			return fmt.Errorf("invalid matches: %w", err)
+		}
+		p.pattern = re
+	}
+	return nil
+}
+
+func (p *Predicate) empty() bool {
+	return p.EqualTo == nil && p.Contains == "" && p.Matches == "" && !p.Absent
+}
+
+// test passes when any of the values satisfies the predicate, or, for
+// Absent, when there are none.
+func (p *Predicate) test(values []string) bool {
+	if p.Absent {
+		return len(values) == 0
+	}
+	for _, v := range values {
+		if p.testValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Predicate) testValue(v string) bool {
+	if p.EqualTo != nil && v != *p.EqualTo {
+		return false
+	}
+	if p.Contains != "" && !strings.Contains(v, p.Contains) {
+		return false
+	}
+	if p.pattern != nil && !p.pattern.MatchString(v) {
+		return false
+	}
+	return true
+}
+
+func (b *BodyPattern) compile() error {
+	if b.JSONPath != "" && b.XPath != "" {
+		return fmt.Errorf("json_path and xpath are mutually exclusive")
+	}
+	if b.JSONPath == "" && b.XPath == "" && b.empty() {
+		return fmt.Errorf("empty body pattern")
+	}
+	var err error
+	if b.JSONPath != "" {
+		if b.jsonPath, err = parseJSONPath(b.JSONPath); err != nil {
+			return err
+		}
+	}
+	if b.XPath != "" {
+		if b.xPath, err = parseXPath(b.XPath); err != nil {
+			return err
+		}
+	}
+	return b.Predicate.compile()
+}
+
+func (b *BodyPattern) test(body []byte) bool {
+	var values []string
+	switch {
+	case b.jsonPath != nil:
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return false
+		}
+		values = evalJSONPath(b.jsonPath, doc)
+	case b.xPath != nil:
+		var ok bool
+		if values, ok = evalXPath(b.xPath, body); !ok {
+			return false
+		}
+	case len(body) > 0:
+		values = []string{string(body)}
+	}
+
+	if b.empty() {
+		return len(values) > 0
+	}
+	return b.Predicate.test(values)
+}
+
+func compileFull(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
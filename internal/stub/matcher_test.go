@@ -0,0 +1,112 @@
+package stub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func ptr(s string) *string { return &s }
+
+func compiled(t *testing.T, m Matcher) Matcher {
+	t.Helper()
+	if err := m.Compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	return m
+}
+
+func request(method string, target string, body string, header http.Header) Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	return NewRequest(r, []byte(body))
+}
+
+func TestMatcherRequestLine(t *testing.T) {
+	m := compiled(t, Matcher{
+		Method:      "post",
+		PathPattern: `/v1/orders/(?P<id>\d+)`,
+		Query:       map[string]Predicate{"dry_run": {Absent: true}, "page": {Matches: `\d+`}},
+	})
+
+	params, ok := m.Match(request(http.MethodPost, "/v1/orders/42?page=3", "", nil))
+	if !ok || params["id"] != "42" {
+		t.Fatalf("match = %v, %v", params, ok)
+	}
+	for _, target := range []string{"/v1/orders/42x?page=3", "/v1/orders/42?page=x", "/v1/orders/42?page=1&dry_run=1"} {
+		if _, ok := m.Match(request(http.MethodPost, target, "", nil)); ok {
+			t.Fatalf("%s should not match", target)
+		}
+	}
+	if _, ok := m.Match(request(http.MethodGet, "/v1/orders/42?page=3", "", nil)); ok {
+		t.Fatal("GET should not match")
+	}
+}
+
+func TestMatcherHeadersAndCookies(t *testing.T) {
+	m := compiled(t, Matcher{
+		Headers: map[string]Predicate{"authorization": {Contains: "Bearer "}},
+		Cookies: map[string]Predicate{"session": {EqualTo: ptr("abc")}},
+	})
+
+	header := http.Header{"Authorization": {"Bearer t0k"}, "Cookie": {"theme=dark; session=abc"}}
+	if _, ok := m.Match(request(http.MethodGet, "/", "", header)); !ok {
+		t.Fatal("expected match")
+	}
+	header.Set("Cookie", "session=xyz")
+	if _, ok := m.Match(request(http.MethodGet, "/", "", header)); ok {
+		t.Fatal("wrong cookie should not match")
+	}
+}
+
+func TestMatcherBody(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern BodyPattern
+		body    string
+		want    bool
+	}{
+		{"contains", BodyPattern{Predicate: Predicate{Contains: "refund"}}, `{"type":"refund"}`, true},
+		{"absent", BodyPattern{Predicate: Predicate{Absent: true}}, ``, true},
+		{"json exists", BodyPattern{JSONPath: "$.items[0].sku"}, `{"items":[{"sku":"A1"}]}`, true},
+		{"json missing", BodyPattern{JSONPath: "$.items[1].sku"}, `{"items":[{"sku":"A1"}]}`, false},
+		{"json equal", BodyPattern{JSONPath: "$.amount", Predicate: Predicate{EqualTo: ptr("12.5")}}, `{"amount":12.5}`, true},
+		{"json wildcard", BodyPattern{JSONPath: "$.items[*].sku", Predicate: Predicate{EqualTo: ptr("B2")}}, `{"items":[{"sku":"A1"},{"sku":"B2"}]}`, true},
+		{"json recursive", BodyPattern{JSONPath: "$..id", Predicate: Predicate{Matches: "u-.*"}}, `{"a":{"b":[{"id":"u-1"}]}}`, true},
+		{"json quoted", BodyPattern{JSONPath: "$['user name']", Predicate: Predicate{EqualTo: ptr("bob")}}, `{"user name":"bob"}`, true},
+		{"json invalid body", BodyPattern{JSONPath: "$.a"}, `<a/>`, false},
+		{"xpath text", BodyPattern{XPath: "/order/item[2]/sku", Predicate: Predicate{EqualTo: ptr("B2")}}, `<order><item><sku>A1</sku></item><item><sku>B2</sku></item></order>`, true},
+		{"xpath attr", BodyPattern{XPath: "//item/@id", Predicate: Predicate{EqualTo: ptr("7")}}, `<order><item id="7"/></order>`, true},
+		{"xpath descendant position", BodyPattern{XPath: "//item[2]/sku", Predicate: Predicate{EqualTo: ptr("D4")}}, `<orders><order><item><sku>A1</sku></item></order><order><item><sku>C3</sku></item><item><sku>D4</sku></item></order></orders>`, true},
+		{"xpath descendant position per parent", BodyPattern{XPath: "//item[2]/sku", Predicate: Predicate{EqualTo: ptr("C3")}}, `<orders><order><item><sku>A1</sku></item></order><order><item><sku>C3</sku></item><item><sku>D4</sku></item></order></orders>`, false},
+		{"xpath missing", BodyPattern{XPath: "/order/refund"}, `<order><item/></order>`, false},
+		{"xpath invalid body", BodyPattern{XPath: "/order"}, `{"order":1}`, false},
+	}
+	for _, tc := range cases {
+		m := compiled(t, Matcher{Body: []BodyPattern{tc.pattern}})
+		if _, ok := m.Match(request(http.MethodPost, "/", tc.body, nil)); ok != tc.want {
+			t.Fatalf("%s: match = %v", tc.name, ok)
+		}
+	}
+}
+
+func TestMatcherCompileErrors(t *testing.T) {
+	cases := []Matcher{
+		{Path: "/a", PathPattern: "/a"},
+		{PathPattern: "("},
+		{Query: map[string]Predicate{"q": {Absent: true, Contains: "x"}}},
+		{Body: []BodyPattern{{}}},
+		{Body: []BodyPattern{{JSONPath: "items"}}},
+		{Body: []BodyPattern{{JSONPath: "$.a", XPath: "/a"}}},
+		{Body: []BodyPattern{{XPath: "order"}}},
+		{Body: []BodyPattern{{XPath: "/a[x]"}}},
+	}
+	for i, m := range cases {
+		if err := m.Compile(); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package stub
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+
+	"rudeserver/internal/scenario"
+	"rudeserver/internal/uuid"
+)
+
+// maxBodyMatch caps how much of a request body is read for body patterns.
+const maxBodyMatch = 1 << 20
+
+// Stub serves Response for requests matching Request. When several stubs
+// match, the lowest Priority wins and ties go to the stub added first.
+type Stub struct {
+	ID       string        `json:"id,omitempty" yaml:"id,omitempty"`
+	Name     string        `json:"name,omitempty" yaml:"name,omitempty"`
+	Priority int           `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Request  Matcher       `json:"request" yaml:"request"`
+	Response scenario.Spec `json:"response" yaml:"response"`
+
+	seq uint64
+}
+
+// Compile validates the stub's matcher and response.
+func (s *Stub) Compile() error {
+	if err := s.Request.Compile(); err != nil {
+		return err
+	}
+	if _, err := scenario.FromSpec(s.Response, http.MethodGet, s.keyPath()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// keyPath is the path the stub's limiters, pools and clocks are keyed on:
+// its path or path pattern rather than the concrete request path, so every
+// request the stub answers shares them.
+func (s *Stub) keyPath() string {
+	switch {
+	case s.Request.Path != "":
+		return s.Request.Path
+	case s.Request.PathPattern != "":
+		return s.Request.PathPattern
+	}
+	return "/"
+}
+
+type Store struct {
+	mu    sync.RWMutex
+	stubs []Stub
+	seq   uint64
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Add compiles the stub, assigns an ID when it has none and stores it.
+func (s *Store) Add(stub Stub) (Stub, error) {
+	if err := stub.Compile(); err != nil {
+		return Stub{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stub.ID == "" {
+		stub.ID = uuid.New()
+	}
+	if s.index(stub.ID) >= 0 {
+		return Stub{}, fmt.Errorf("duplicate stub id %s", stub.ID)
+	}
+	s.insert(stub)
+	return stub, nil
+}
+
+// Get returns the stub with the given ID.
+func (s *Store) Get(id string) (Stub, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.index(id); i >= 0 {
+		return s.stubs[i], true
+	}
+	return Stub{}, false
+}
+
+// Update replaces the stub with the given ID, keeping its place among
+// stubs of equal priority.
+func (s *Store) Update(id string, stub Stub) (Stub, bool, error) {
+	if err := stub.Compile(); err != nil {
+		return Stub{}, false, err
+	}
+	stub.ID = id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return Stub{}, false, nil
+	}
+	stub.seq = s.stubs[i].seq
+	s.stubs = slices.Delete(s.stubs, i, i+1)
+	j, _ := slices.BinarySearchFunc(s.stubs, stub, compareStubs)
+	s.stubs = slices.Insert(s.stubs, j, stub)
+	return stub, true, nil
+}
+
+// Remove deletes the stub with the given ID.
+func (s *Store) Remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return false
+	}
+	s.stubs = slices.Delete(s.stubs, i, i+1)
+	return true
+}
+
+// Replace swaps all stubs at once; on error the store is left unchanged.
+func (s *Store) Replace(stubs []Stub) error {
+	next := &Store{}
+	for i := range stubs {
+		if _, err := next.Add(stubs[i]); err != nil {
+			name := stubs[i].Name
+			if name == "" {
+				name = fmt.Sprintf("%d", i+1)
+			}
+			return fmt.Errorf("stub %s: %w", name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs = next.stubs
+	s.seq = next.seq
+	return nil
+}
+
+// List returns the stubs in match order.
+func (s *Store) List() []Stub {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.stubs)
+}
+
+// Match returns the winning stub for req and its path parameters.
+func (s *Store) Match(req Request) (Stub, map[string]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.stubs {
+		if params, ok := s.stubs[i].Request.Match(req); ok {
+			return s.stubs[i], params, true
+		}
+	}
+	return Stub{}, nil, false
+}
+
+// Resolve serves the winning stub ahead of the smart-URL parser. Request
+// bodies are only read when a stub has body patterns, and are replayed
+// to the handlers downstream. The stub is not known until the body has
+// been read, so the first maxBodyMatch bytes are read at full speed even
+// when the winning stub sets bw_up; only the rest is throttled.
+func (s *Store) Resolve(r *http.Request) (scenario.Scenario, bool, error) {
+	var body []byte
+	if s.needsBody() && r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxBodyMatch))
+		if err != nil {
+			return scenario.Scenario{}, true, fmt.Errorf("read body: %w", err)
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	}
+
+	stub, params, ok := s.Match(NewRequest(r, body))
+	if !ok {
+		return scenario.Scenario{}, false, nil
+	}
+	sc, err := scenario.FromSpec(stub.Response, r.Method, stub.keyPath())
+	if err != nil {
+		return scenario.Scenario{}, true, err
+	}
+	sc.PathParams = params
+	return sc, true, nil
+}
+
+func (s *Store) needsBody() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.stubs {
+		if s.stubs[i].Request.needsBody() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) index(id string) int {
+	return slices.IndexFunc(s.stubs, func(stub Stub) bool { return stub.ID == id })
+}
+
+func (s *Store) insert(stub Stub) {
+	s.seq++
+	stub.seq = s.seq
+	i, _ := slices.BinarySearchFunc(s.stubs, stub, compareStubs)
+	s.stubs = slices.Insert(s.stubs, i, stub)
+}
+
+func compareStubs(a, b Stub) int {
+	return cmp.Or(cmp.Compare(a.Priority, b.Priority), cmp.Compare(a.seq, b.seq))
+}
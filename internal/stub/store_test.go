@@ -0,0 +1,120 @@
+package stub
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"rudeserver/internal/scenario"
+)
+
+func TestStorePriority(t *testing.T) {
+	store := NewStore()
+	mustAdd := func(s Stub) Stub {
+		t.Helper()
+		added, err := store.Add(s)
+		if err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		return added
+	}
+
+	fallback := mustAdd(Stub{Name: "fallback", Priority: 10, Response: scenario.Spec{Status: 404}})
+	first := mustAdd(Stub{Name: "first", Request: Matcher{Path: "/orders"}, Response: scenario.Spec{Status: 200}})
+	mustAdd(Stub{Name: "second", Request: Matcher{Path: "/orders"}, Response: scenario.Spec{Status: 201}})
+	urgent := mustAdd(Stub{Name: "urgent", Priority: -1, Request: Matcher{Path: "/orders", Method: "DELETE"}, Response: scenario.Spec{Status: 503}})
+
+	if fallback.ID == "" || fallback.ID == first.ID {
+		t.Fatalf("ids = %q, %q", fallback.ID, first.ID)
+	}
+
+	cases := map[string]string{
+		"GET /orders":    first.ID,
+		"DELETE /orders": urgent.ID,
+		"GET /other":     fallback.ID,
+	}
+	for line, want := range cases {
+		method, path, _ := strings.Cut(line, " ")
+		got, _, ok := store.Match(request(method, path, "", nil))
+		if !ok || got.ID != want {
+			t.Fatalf("%s matched %q, want %q", line, got.Name, want)
+		}
+	}
+
+	if _, err := store.Add(Stub{ID: first.ID}); err == nil {
+		t.Fatal("expected duplicate id error")
+	}
+	if names := len(store.List()); names != 4 {
+		t.Fatalf("list = %d", names)
+	}
+}
+
+func TestStoreExtremePriorities(t *testing.T) {
+	store := NewStore()
+	for _, p := range []int{math.MaxInt, math.MinInt, 0, math.MinInt + 1, math.MaxInt - 1} {
+		if _, err := store.Add(Stub{Name: strconv.Itoa(p), Priority: p, Response: scenario.Spec{Status: 200}}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	got, _, _ := store.Match(request("GET", "/", "", nil))
+	if got.Priority != math.MinInt {
+		t.Fatalf("matched priority %d, want the lowest", got.Priority)
+	}
+	list := store.List()
+	for i := 1; i < len(list); i++ {
+		if list[i-1].Priority > list[i].Priority {
+			t.Fatalf("stubs out of order: %d before %d", list[i-1].Priority, list[i].Priority)
+		}
+	}
+}
+
+func TestStoreReplaceKeepsStoreOnError(t *testing.T) {
+	store := NewStore()
+	if err := store.Replace([]Stub{{Name: "ok"}}); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	err := store.Replace([]Stub{{Name: "ok"}, {Name: "bad", Request: Matcher{PathPattern: "("}}})
+	if err == nil || !strings.Contains(err.Error(), "stub bad") {
+		t.Fatalf("err = %v", err)
+	}
+	if list := store.List(); len(list) != 1 || list[0].Name != "ok" {
+		t.Fatalf("list = %+v", list)
+	}
+}
+
+func TestStoreResolveReplaysBody(t *testing.T) {
+	store := NewStore()
+	_, err := store.Add(Stub{
+		Request: Matcher{
+			Method:      "POST",
+			PathPattern: `/users/(?P<id>[^/]+)`,
+			Body:        []BodyPattern{{JSONPath: "$.role", Predicate: Predicate{EqualTo: ptr("admin")}}},
+		},
+		Response: scenario.Spec{Status: 403, Body: "no"},
+	})
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/users/u1", strings.NewReader(`{"role":"admin"}`))
+	sc, ok, err := store.Resolve(r)
+	if err != nil || !ok {
+		t.Fatalf("resolve: ok = %v, err = %v", ok, err)
+	}
+	if sc.StatusCode != 403 || sc.Body != "no" || sc.PathParams["id"] != "u1" || sc.NormalizedPath != `/users/(?P<id>[^/]+)` {
+		t.Fatalf("scenario = %+v", sc)
+	}
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != `{"role":"admin"}` {
+		t.Fatalf("replayed body = %q", body)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/users/u1", strings.NewReader(`{"role":"viewer"}`))
+	if _, ok, _ := store.Resolve(r); ok {
+		t.Fatal("viewer should fall through")
+	}
+}
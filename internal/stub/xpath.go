@@ -0,0 +1,178 @@
+package stub
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlStep is one step of an XPath expression. The supported subset is
+// absolute paths of element names or *, the // descendant axis, 1-based
+// [N] positions among siblings, and a final @attr or text() step.
+type xmlStep struct {
+	name       string
+	descendant bool
+	position   int
+	attr       string
+	text       bool
+}
+
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     strings.Builder
+}
+
+func parseXPath(expr string) ([]xmlStep, error) {
+	rest := strings.TrimSpace(expr)
+	if !strings.HasPrefix(rest, "/") {
+		return nil, fmt.Errorf("invalid xpath %q: must start with /", expr)
+	}
+
+	var steps []xmlStep
+	for rest != "" {
+		var step xmlStep
+		if strings.HasPrefix(rest, "//") {
+			step.descendant = true
+			rest = rest[2:]
+		} else if strings.HasPrefix(rest, "/") {
+			rest = rest[1:]
+		} else {
+			return nil, fmt.Errorf("invalid xpath %q", expr)
+		}
+
+		part := rest
+		if end := strings.IndexByte(rest, '/'); end >= 0 {
+			part, rest = rest[:end], rest[end:]
+		} else {
+			rest = ""
+		}
+
+		if last := rest == ""; last && strings.HasPrefix(part, "@") {
+			step.attr = part[1:]
+		} else if last && part == "text()" {
+			step.text = true
+		} else {
+			name, pos, hasPos := strings.Cut(part, "[")
+			if hasPos {
+				n, err := strconv.Atoi(strings.TrimSuffix(pos, "]"))
+				if err != nil || n < 1 || !strings.HasSuffix(pos, "]") {
+					return nil, fmt.Errorf("invalid xpath %q", expr)
+				}
+				step.position = n
+			}
+			step.name = name
+		}
+		if step.name == "" && step.attr == "" && !step.text || step.descendant && step.name == "" {
+			return nil, fmt.Errorf("invalid xpath %q", expr)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// evalXPath returns the string values of the selected nodes: attribute
+// values, or the trimmed text content of elements. It reports false when
+// the body is not XML.
+func evalXPath(steps []xmlStep, body []byte) ([]string, bool) {
+	root, ok := parseXML(body)
+	if !ok {
+		return nil, false
+	}
+
+	nodes := []*xmlNode{root}
+	for _, step := range steps {
+		if step.attr != "" || step.text {
+			var values []string
+			for _, node := range nodes {
+				if step.text {
+					values = append(values, strings.TrimSpace(node.text.String()))
+					continue
+				}
+				for _, a := range node.attrs {
+					if a.Name.Local == step.attr {
+						values = append(values, a.Value)
+					}
+				}
+			}
+			return values, true
+		}
+
+		// As in XPath, //x[N] is the Nth x child of each parent below the
+		// context node, not the Nth x in the whole subtree.
+		var next []*xmlNode
+		seen := make(map[*xmlNode]bool)
+		for _, node := range nodes {
+			parents := []*xmlNode{node}
+			if step.descendant {
+				parents = append(parents, descendants(node)...)
+			}
+			for _, parent := range parents {
+				matched := 0
+				for _, c := range parent.children {
+					if step.name != "*" && c.name != step.name {
+						continue
+					}
+					matched++
+					if (step.position == 0 || step.position == matched) && !seen[c] {
+						seen[c] = true
+						next = append(next, c)
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		values = append(values, strings.TrimSpace(textContent(node)))
+	}
+	return values, true
+}
+
+// parseXML returns a synthetic document node whose only child is the root element.
+func parseXML(body []byte) (*xmlNode, bool) {
+	doc := &xmlNode{}
+	stack := []*xmlNode{doc}
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return doc, err == io.EOF && len(stack) == 1 && len(doc.children) == 1
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(t)
+		}
+	}
+}
+
+func descendants(node *xmlNode) []*xmlNode {
+	var out []*xmlNode
+	for _, c := range node.children {
+		out = append(out, c)
+		out = append(out, descendants(c)...)
+	}
+	return out
+}
+
+func textContent(node *xmlNode) string {
+	var b strings.Builder
+	b.WriteString(node.text.String())
+	for _, c := range node.children {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
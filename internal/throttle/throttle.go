@@ -0,0 +1,75 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+const maxBurst = 64 * 1024
+
+// newLimiter allows bps bytes per second in bursts of about 100ms worth of data.
+func newLimiter(bps int64) *rate.Limiter {
+	burst := int(min(max(bps/10, 1), maxBurst))
+	return rate.NewLimiter(rate.Limit(bps), burst)
+}
+
+type reader struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+// ReadCloser paces reads from rc to bps bytes per second.
+func ReadCloser(ctx context.Context, rc io.ReadCloser, bps int64) io.ReadCloser {
+	return &reader{ReadCloser: rc, ctx: ctx, limiter: newLimiter(bps)}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+// ResponseWriter paces body writes to w to bps bytes per second.
+func ResponseWriter(ctx context.Context, w http.ResponseWriter, bps int64) http.ResponseWriter {
+	return &responseWriter{ResponseWriter: w, ctx: ctx, limiter: newLimiter(bps)}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), w.limiter.Burst())
+		if err := w.limiter.WaitN(w.ctx, n); err != nil {
+			return written, err
+		}
+		m, err := w.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		// Push each slice onto the wire so the pacing is visible to the client.
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,54 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCloserPaces(t *testing.T) {
+	body := io.NopCloser(strings.NewReader(strings.Repeat("a", 300)))
+	r := ReadCloser(context.Background(), body, 1000)
+
+	start := time.Now()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != 300 {
+		t.Fatalf("len = %d", len(got))
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+}
+
+func TestResponseWriterPaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := ResponseWriter(context.Background(), rec, 1000)
+
+	start := time.Now()
+	n, err := w.Write([]byte(strings.Repeat("b", 300)))
+	if err != nil || n != 300 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+	if rec.Body.Len() != 300 {
+		t.Fatalf("body len = %d", rec.Body.Len())
+	}
+}
+
+func TestResponseWriterStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := ResponseWriter(ctx, httptest.NewRecorder(), 10)
+
+	if _, err := w.Write([]byte(strings.Repeat("c", 100))); err == nil {
+		t.Fatal("expected error after cancel")
+	}
+}
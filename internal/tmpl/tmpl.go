@@ -0,0 +1,90 @@
+package tmpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"rudeserver/internal/uuid"
+)
+
+// Data is what response templates can see about the incoming request.
+type Data struct {
+	Method   string
+	Path     string
+	Query    url.Values
+	Headers  http.Header
+	Body     string
+	JSON     any
+	ClientIP string
+	Count    int
+	Now      time.Time
+	Params   map[string]string
+}
+
+// NewData collects template data from r. body is the already-read request
+// body; JSON holds it decoded when it is valid JSON.
+func NewData(r *http.Request, body []byte, clientIP string, count int) Data {
+	data := Data{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.Query(),
+		Headers:  r.Header,
+		Body:     string(body),
+		ClientIP: clientIP,
+		Count:    count,
+		Now:      time.Now().UTC(),
+	}
+	var parsed any
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		data.JSON = parsed
+	}
+	return data
+}
+
+var funcs = template.FuncMap{
+	"uuid": uuid.New,
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Render executes text as a Go text/template against data.
+func Render(text string, data Data) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	t, err := template.New("response").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderHeaders renders every header value, returning a new header map.
+func RenderHeaders(headers http.Header, data Data) (http.Header, error) {
+	out := make(http.Header, len(headers))
+	for name, values := range headers {
+		for _, value := range values {
+			rendered, err := Render(value, data)
+			if err != nil {
+				return nil, err
+			}
+			out[name] = append(out[name], rendered)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package tmpl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRenderEchoesRequest(t *testing.T) {
+	body := []byte(`{"order":{"id":42}}`)
+	req := httptest.NewRequest(http.MethodPost, "/http/orders?tenant=acme", strings.NewReader(string(body)))
+	req.Header.Set("X-Request-Id", "req-1")
+	data := NewData(req, body, "203.0.113.1", 3)
+
+	text := `{{.Method}} {{.Path}} {{.Query.Get "tenant"}} {{.Headers.Get "X-Request-Id"}} {{.JSON.order.id}} {{.ClientIP}} #{{.Count}}`
+	got, err := Render(text, data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "POST /http/orders acme req-1 42 203.0.113.1 #3"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenderFuncs(t *testing.T) {
+	data := NewData(httptest.NewRequest(http.MethodGet, "/", nil), nil, "", 1)
+
+	got, err := Render(`{{uuid}}`, data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got) {
+		t.Fatalf("uuid = %q", got)
+	}
+
+	got, err = Render(`{{json .Method}} {{.Now.Year}}`, data)
+	if err != nil || !strings.HasPrefix(got, `"GET" 2`) {
+		t.Fatalf("got %q, err = %v", got, err)
+	}
+}
+
+func TestRenderLeavesPlainTextAlone(t *testing.T) {
+	got, err := Render(`{"ok":true}`, Data{})
+	if err != nil || got != `{"ok":true}` {
+		t.Fatalf("got %q, err = %v", got, err)
+	}
+}
+
+func TestRenderInvalidTemplate(t *testing.T) {
+	if _, err := Render(`{{.Method`, Data{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRenderHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	headers := http.Header{"X-Echo": {`{{.Headers.Get "X-Request-Id"}}`}, "X-Static": {"1"}}
+
+	got, err := RenderHeaders(headers, NewData(req, nil, "", 1))
+	if err != nil {
+		t.Fatalf("render headers: %v", err)
+	}
+	if got.Get("X-Echo") != "abc" || got.Get("X-Static") != "1" {
+		t.Fatalf("headers = %v", got)
+	}
+}
@@ -49,16 +49,17 @@ func toSummaries(entries []reqlog.Entry) []map[string]any {
 	out := make([]map[string]any, 0, len(entries))
 	for _, e := range entries {
 		out = append(out, map[string]any{
-			"id":          e.ID,
-			"timestamp":   e.Timestamp,
-			"method":      e.Method,
-			"path":        e.Path,
-			"query":       e.Query,
-			"protocol":    e.Protocol,
-			"client_ip":   e.ClientIP,
-			"status":      e.Status,
-			"duration_ms": e.Duration,
+			"id":           e.ID,
+			"timestamp":    e.Timestamp,
+			"method":       e.Method,
+			"path":         e.Path,
+			"query":        e.Query,
+			"protocol":     e.Protocol,
+			"client_ip":    e.ClientIP,
+			"status":       e.Status,
+			"duration_ms":  e.Duration,
 			"content_type": e.ContentType,
+			"client_gone":  e.ClientGone,
 		})
 	}
 	return out
@@ -66,30 +67,33 @@ func toSummaries(entries []reqlog.Entry) []map[string]any {
 
 func toDetail(e reqlog.Entry) map[string]any {
 	return map[string]any{
-		"id":           e.ID,
-		"timestamp":    e.Timestamp,
-		"method":       e.Method,
-		"path":         e.Path,
-		"query":        e.Query,
-		"protocol":     e.Protocol,
-		"client_ip":    e.ClientIP,
-		"status":       e.Status,
-		"duration_ms":  e.Duration,
-		"user_agent":   e.UserAgent,
-		"req_headers":  e.ReqHeaders,
-		"res_headers":  e.ResHeaders,
-		"req_body":     string(e.ReqBody),
-		"res_body":     string(e.ResBody),
-		"req_trunc":    e.ReqTruncated,
-		"res_trunc":    e.ResTruncated,
-		"req_size":     e.ReqSize,
-		"res_size":     e.ResSize,
-		"req_utf8":     e.ReqBodyIsUTF,
-		"res_utf8":     e.ResBodyIsUTF,
-		"req_b64":      e.ReqBodyB64,
-		"res_b64":      e.ResBodyB64,
-		"content_type": e.ContentType,
-		"req_error":    e.ReqError,
-		"res_error":    e.ResError,
+		"id":                e.ID,
+		"timestamp":         e.Timestamp,
+		"method":            e.Method,
+		"path":              e.Path,
+		"query":             e.Query,
+		"protocol":          e.Protocol,
+		"client_ip":         e.ClientIP,
+		"status":            e.Status,
+		"duration_ms":       e.Duration,
+		"user_agent":        e.UserAgent,
+		"req_headers":       e.ReqHeaders,
+		"res_headers":       e.ResHeaders,
+		"req_body":          string(e.ReqBody),
+		"res_body":          string(e.ResBody),
+		"req_trunc":         e.ReqTruncated,
+		"res_trunc":         e.ResTruncated,
+		"req_size":          e.ReqSize,
+		"res_size":          e.ResSize,
+		"req_utf8":          e.ReqBodyIsUTF,
+		"res_utf8":          e.ResBodyIsUTF,
+		"req_b64":           e.ReqBodyB64,
+		"res_b64":           e.ResBodyB64,
+		"content_type":      e.ContentType,
+		"req_error":         e.ReqError,
+		"res_error":         e.ResError,
+		"client_gone":       e.ClientGone,
+		"client_gone_at":    e.ClientGoneAt,
+		"client_gone_phase": e.ClientGonePhase,
 	}
 }
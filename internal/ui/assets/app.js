@@ -58,7 +58,7 @@ function renderList() {
       <div class="request-path">${item.method} ${item.path}</div>
       <div class="request-meta">
         <span>${item.client_ip || ''}</span>
-        <span>${item.duration_ms || 0} ms</span>
+        <span>${item.client_gone ? 'gone · ' : ''}${item.duration_ms || 0} ms</span>
       </div>
     `;
     li.addEventListener('click', () => loadDetail(item.id));
@@ -119,6 +119,9 @@ async function loadDetail(id) {
       <div>Duration</div><div>${detail.duration_ms} ms</div>
       <div>User Agent</div><div>${detail.user_agent || ''}</div>
       <div>Query</div><div>${detail.query || ''}</div>
+      <div>Client</div><div>${detail.client_gone
+        ? `disconnected while ${detail.client_gone_phase} at ${new Date(detail.client_gone_at).toLocaleTimeString()}`
+        : 'connected'}</div>
     </div>
   `;
 